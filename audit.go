@@ -0,0 +1,599 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// AuditPolicy configures the audit log of the state mutations
+type AuditPolicy struct {
+	// Table is the name of the audit table, keyed by PersistenceID and Entry
+	Table string
+	// TTL is how long the audit entries are kept, zero keeps them forever. The entries carry
+	// their expiry in the ExpiresAt attribute, in Unix seconds, for the DynamoDB TTL.
+	TTL time.Duration
+	// SigningKey signs every entry with HMAC-SHA256 along with the signature of the previous
+	// entry of the state, so that an entry modified or removed after it was written fails
+	// AuditTrail with ErrAuditTampered. The signature of the newest entry is kept in the head
+	// item of the trail and in the state item, so that removing the newest entries is detected as
+	// well. The entries are not signed when empty.
+	SigningKey []byte
+}
+
+// AuditEntry records a mutation of a durable state
+type AuditEntry struct {
+	PersistenceID string
+	VersionNumber uint64
+	// Operation is OperationWriteState or OperationDeleteState
+	Operation string
+	// AuditedAt is the time of the mutation, read from the store clock
+	AuditedAt time.Time
+	// Actor and Metadata are the caller information given with WithAuditContext
+	Actor    string
+	Metadata map[string]string
+}
+
+// audit appends the audit entries to the audit table
+type audit struct {
+	policy AuditPolicy
+}
+
+// auditContext is the caller information recorded in the audit entries
+type auditContext struct {
+	actor    string
+	metadata map[string]string
+}
+
+// auditContextKey is the context key of the audit caller information
+type auditContextKey struct{}
+
+// WithAuditContext returns a context recording the given actor and metadata in the audit
+// entries of the mutations made with it, for instance the user and the request ID
+func WithAuditContext(ctx context.Context, actor string, metadata map[string]string) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, auditContext{actor: actor, metadata: metadata})
+}
+
+// auditHeadEntry is the sort key of the head of the audit trail of a state: the item holding the
+// signature of its newest entry, which appending the next entry is conditioned on. It sorts after
+// the entries.
+const auditHeadEntry = "HEAD"
+
+// maxAuditAttempts bounds the attempts of a mutation whose audit entry keeps being preceded by
+// the entries of concurrent mutations of the same state
+const maxAuditAttempts = 5
+
+// auditAppend appends an audit entry to the trail of a state
+type auditAppend struct {
+	// entry puts the audit entry
+	entry *types.Put
+	// head moves the head of the trail to the entry provided it still is the entry the new one is
+	// chained to, nil when the entries are not signed
+	head *types.Put
+	// signature is the signature of the entry, anchored in the state item, nil when the entries
+	// are not signed
+	signature []byte
+}
+
+// actions returns the transaction actions appending the entry
+func (a *auditAppend) actions() []types.TransactWriteItem {
+	actions := []types.TransactWriteItem{{Put: a.entry}}
+	if a.head != nil {
+		actions = append(actions, types.TransactWriteItem{Put: a.head})
+	}
+	return actions
+}
+
+// auditActionCount returns the number of transaction actions appending an audit entry
+func (d *DynamoDurableStore) auditActionCount() int {
+	switch {
+	case d.audit == nil:
+		return 0
+	case len(d.audit.policy.SigningKey) > 0:
+		return 2
+	}
+	return 1
+}
+
+// newAuditAppend returns the append of an audit entry of the given operation on a version of the
+// state stored under the given partition key, nil when the audit log is disabled. With a signing
+// key, the head of the trail of the state is read first to chain the new entry to it.
+func (d *DynamoDurableStore) newAuditAppend(ctx context.Context, partitionKey string, version uint64, operation string) (*auditAppend, error) {
+	if d.audit == nil {
+		return nil, nil
+	}
+
+	caller, _ := ctx.Value(auditContextKey{}).(auditContext)
+	entry := &AuditEntry{
+		PersistenceID: partitionKey,
+		VersionNumber: version,
+		Operation:     operation,
+		AuditedAt:     d.clock.Now(),
+		Actor:         caller.actor,
+		Metadata:      caller.metadata,
+	}
+
+	item := map[string]types.AttributeValue{
+		"PersistenceID": &types.AttributeValueMemberS{Value: partitionKey},                                       // Partition key
+		"Entry":         &types.AttributeValueMemberS{Value: auditEntryKey(entry.AuditedAt, version, operation)}, // Sort key
+		"VersionNumber": &types.AttributeValueMemberN{Value: strconv.FormatUint(version, 10)},
+		"Operation":     &types.AttributeValueMemberS{Value: operation},
+		"AuditedAt":     &types.AttributeValueMemberN{Value: strconv.FormatInt(entry.AuditedAt.UnixMilli(), 10)},
+	}
+	if entry.Actor != "" {
+		item["Actor"] = &types.AttributeValueMemberS{Value: entry.Actor}
+	}
+	if len(entry.Metadata) > 0 {
+		metadata := make(map[string]types.AttributeValue, len(entry.Metadata))
+		for key, value := range entry.Metadata {
+			metadata[key] = &types.AttributeValueMemberS{Value: value}
+		}
+		item["Metadata"] = &types.AttributeValueMemberM{Value: metadata}
+	}
+	var expiresAt types.AttributeValue
+	if d.audit.policy.TTL > 0 {
+		expiresAt = &types.AttributeValueMemberN{Value: strconv.FormatInt(entry.AuditedAt.Add(d.audit.policy.TTL).Unix(), 10)}
+		item["ExpiresAt"] = expiresAt
+	}
+
+	// the entry key is unique, a collision must not overwrite an entry
+	audit := &auditAppend{
+		entry: &types.Put{
+			TableName:           aws.String(d.audit.policy.Table),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(Entry)"),
+		},
+	}
+	if len(d.audit.policy.SigningKey) == 0 {
+		return audit, nil
+	}
+
+	previous, hasHead, err := d.auditHead(ctx, partitionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(previous) > 0 {
+		item["PreviousSignature"] = &types.AttributeValueMemberB{Value: previous}
+	}
+	audit.signature = d.audit.sign(entry, previous)
+	item["Signature"] = &types.AttributeValueMemberB{Value: audit.signature}
+
+	// the head expires along with the entry it points to
+	head := map[string]types.AttributeValue{
+		"PersistenceID": &types.AttributeValueMemberS{Value: partitionKey},
+		"Entry":         &types.AttributeValueMemberS{Value: auditHeadEntry},
+		"Signature":     &types.AttributeValueMemberB{Value: audit.signature},
+	}
+	if expiresAt != nil {
+		head["ExpiresAt"] = expiresAt
+	}
+	audit.head = &types.Put{
+		TableName:                           aws.String(d.audit.policy.Table),
+		Item:                                head,
+		ConditionExpression:                 aws.String("attribute_not_exists(Entry)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if hasHead {
+		audit.head.ConditionExpression = aws.String("Signature = :previous")
+		audit.head.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":previous": &types.AttributeValueMemberB{Value: previous},
+		}
+	}
+	return audit, nil
+}
+
+// appendAudited runs a mutation of the state stored under the given partition key along with the
+// append of its audit entry, nil when the audit log is disabled. The mutation is retried with a
+// new entry when another mutation of the state appended its entry since the head of the trail was
+// read, so that the trail never forks.
+func (d *DynamoDurableStore) appendAudited(ctx context.Context, partitionKey string, version uint64, operation string, mutate func(audit *auditAppend) error) error {
+	for attempt := 1; ; attempt++ {
+		audit, err := d.newAuditAppend(ctx, partitionKey, version, operation)
+		if err != nil {
+			return err
+		}
+		err = mutate(audit)
+		if attempt >= maxAuditAttempts || !isAuditConflict(err) {
+			return err
+		}
+		d.logger.Warn("retrying the audited mutation", "operation", operation, "persistenceID", partitionKey, "attempt", attempt)
+	}
+}
+
+// isAuditConflict reports whether a transaction was canceled by the condition on the head of an
+// audit trail, which another mutation of the state moved
+func isAuditConflict(err error) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for _, reason := range canceled.CancellationReasons {
+		if aws.ToString(reason.Code) == "ConditionalCheckFailed" && parseDynamoString(reason.Item["Entry"]) == auditHeadEntry {
+			return true
+		}
+	}
+	return false
+}
+
+// putAudit appends an audit entry after the mutation it records, anchoring it in the state item
+// stored under the given key provided the item still holds the given version
+func (d *DynamoDurableStore) putAudit(ctx context.Context, audit *auditAppend, stateKey string, version uint64, operation string) error {
+	if audit == nil {
+		return nil
+	}
+
+	actions := audit.actions()
+	if audit.signature != nil {
+		actions = append(actions, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                aws.String(d.schema.TableName),
+				Key:                      d.schema.key(stateKey),
+				UpdateExpression:         aws.String("SET #AuditSignature = :signature"),
+				ConditionExpression:      aws.String("#VersionNumber = :version"),
+				ExpressionAttributeNames: d.schema.names("AuditSignature", "VersionNumber"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":signature": &types.AttributeValueMemberB{Value: audit.signature},
+					":version":   &types.AttributeValueMemberN{Value: strconv.FormatUint(version, 10)},
+				},
+			},
+		})
+	}
+
+	err := d.auditTransaction(ctx, actions, operation)
+	var canceled *types.TransactionCanceledException
+	if audit.signature != nil && errors.As(err, &canceled) && len(canceled.CancellationReasons) == len(actions) &&
+		aws.ToString(canceled.CancellationReasons[len(actions)-1].Code) == "ConditionalCheckFailed" {
+		// a newer version of the state was written meanwhile, its own entry anchors the trail
+		err = d.auditTransaction(ctx, actions[:len(actions)-1], operation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append the audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditTransaction runs the actions appending an audit entry in a transaction
+func (d *DynamoDurableStore) auditTransaction(ctx context.Context, actions []types.TransactWriteItem, operation string) error {
+	return d.execute(ctx, operation, func(ctx context.Context) error {
+		resp, err := d.client.Load().TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems:          actions,
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			for index := range resp.ConsumedCapacity {
+				d.observeConsumedCapacity(ctx, operation, &resp.ConsumedCapacity[index])
+			}
+		}
+		return err
+	})
+}
+
+// auditHead returns the signature of the newest audit entry of the state stored under the given
+// partition key, nil when it has none, and whether it is recorded by the head of the trail. The
+// trails started before the heads were introduced have none: their newest entry is read instead.
+func (d *DynamoDurableStore) auditHead(ctx context.Context, partitionKey string) ([]byte, bool, error) {
+	var resp *dynamodb.GetItemOutput
+	err := d.execute(ctx, OperationAuditTrail, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(d.audit.policy.Table),
+			Key: map[string]types.AttributeValue{
+				"PersistenceID": &types.AttributeValueMemberS{Value: partitionKey},
+				"Entry":         &types.AttributeValueMemberS{Value: auditHeadEntry},
+			},
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationAuditTrail, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch the head of the audit trail: %w", err)
+	}
+	if resp.Item != nil {
+		return parseDynamoBytes(resp.Item["Signature"]), true, nil
+	}
+
+	previous, err := d.latestAuditSignature(ctx, partitionKey)
+	return previous, false, err
+}
+
+// latestAuditSignature returns the signature of the newest audit entry of the state stored
+// under the given partition key, nil when it has none
+func (d *DynamoDurableStore) latestAuditSignature(ctx context.Context, partitionKey string) ([]byte, error) {
+	var resp *dynamodb.QueryOutput
+	err := d.execute(ctx, OperationAuditTrail, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(d.audit.policy.Table),
+			KeyConditionExpression: aws.String("PersistenceID = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id": &types.AttributeValueMemberS{Value: partitionKey},
+			},
+			ProjectionExpression:   aws.String("Signature"),
+			ScanIndexForward:       aws.Bool(false),
+			Limit:                  aws.Int32(1),
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationAuditTrail, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest audit entry: %w", err)
+	}
+	if len(resp.Items) == 0 {
+		return nil, nil
+	}
+	return parseDynamoBytes(resp.Items[0]["Signature"]), nil
+}
+
+// auditEntryKey returns the sort key of an audit entry, ordering the entries of a state by time.
+// The entries written before the time was part of the key are keyed by version#operation and
+// sort before the others.
+func auditEntryKey(auditedAt time.Time, version uint64, operation string) string {
+	return fmt.Sprintf("%020d#%020d#%s", auditedAt.UnixNano(), version, operation)
+}
+
+// isChainedEntry reports whether the audit entry of the given sort key is chained to the
+// previous one, the entries keyed by version#operation predating the chaining
+func isChainedEntry(entryKey string) bool {
+	return strings.Count(entryKey, "#") == 2
+}
+
+// sign returns the HMAC-SHA256 of the canonical form of the entry followed by the signature
+// of the previous entry
+func (a *audit) sign(entry *AuditEntry, previous []byte) []byte {
+	var canonical strings.Builder
+	canonical.WriteString(entry.PersistenceID + "\n")
+	canonical.WriteString(strconv.FormatUint(entry.VersionNumber, 10) + "\n")
+	canonical.WriteString(entry.Operation + "\n")
+	canonical.WriteString(strconv.FormatInt(entry.AuditedAt.UnixMilli(), 10) + "\n")
+	canonical.WriteString(entry.Actor + "\n")
+	keys := make([]string, 0, len(entry.Metadata))
+	for key := range entry.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		canonical.WriteString(strconv.Quote(key) + "=" + strconv.Quote(entry.Metadata[key]) + "\n")
+	}
+	if len(previous) > 0 {
+		canonical.WriteString(hex.EncodeToString(previous) + "\n")
+	}
+
+	mac := hmac.New(sha256.New, a.policy.SigningKey)
+	mac.Write([]byte(canonical.String()))
+	return mac.Sum(nil)
+}
+
+// AuditTrail returns the audit entries of the given persistence ID, oldest first. It fails with
+// ErrAuditTampered when an entry does not match its signature or does not follow the previous
+// entry of the trail, or when the newest entries recorded by the head of the trail or by the
+// state item are missing. The oldest entry is not checked against its previous one, which may
+// have expired.
+func (d *DynamoDurableStore) AuditTrail(ctx context.Context, persistenceID string) ([]*AuditEntry, error) {
+	if d.audit == nil {
+		return nil, errors.New("audit is not enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the anchors are read before the entries, so that an entry appended meanwhile is listed
+	anchors, err := d.auditAnchors(ctx, prefix+persistenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.audit.policy.Table),
+		KeyConditionExpression: aws.String("PersistenceID = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: prefix + persistenceID},
+		},
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+
+	var (
+		entries    []*AuditEntry
+		previous   []byte
+		signatures = make(map[string]bool)
+	)
+	for {
+		var resp *dynamodb.QueryOutput
+		err := d.execute(ctx, OperationAuditTrail, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Query(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationAuditTrail, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query the audit trail of persistenceID=%s: %w", persistenceID, err)
+		}
+
+		for _, attributes := range resp.Items {
+			if parseDynamoString(attributes["Entry"]) == auditHeadEntry {
+				continue
+			}
+			entry, err := d.audit.unmarshal(attributes)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.audit.verify(attributes, entry, previous, len(entries) > 0); err != nil {
+				return nil, err
+			}
+			previous = parseDynamoBytes(attributes["Signature"])
+			signatures[string(previous)] = true
+			entry.PersistenceID = persistenceID
+			entries = append(entries, entry)
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			if err := d.audit.verifyAnchors(anchors, signatures, entries, d.clock.Now()); err != nil {
+				return nil, fmt.Errorf("audit trail of persistenceID=%s: %w", persistenceID, err)
+			}
+			return entries, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// unmarshal converts the attributes of an audit item into an entry
+func (a *audit) unmarshal(attributes map[string]types.AttributeValue) (*AuditEntry, error) {
+	version, err := parseDynamoUint64(attributes["VersionNumber"])
+	if err != nil {
+		return nil, err
+	}
+	auditedAt, err := parseDynamoInt64(attributes["AuditedAt"])
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &AuditEntry{
+		PersistenceID: parseDynamoString(attributes["PersistenceID"]),
+		VersionNumber: version,
+		Operation:     parseDynamoString(attributes["Operation"]),
+		AuditedAt:     time.UnixMilli(auditedAt),
+		Actor:         parseDynamoString(attributes["Actor"]),
+	}
+	if metadata, ok := attributes["Metadata"].(*types.AttributeValueMemberM); ok {
+		entry.Metadata = make(map[string]string, len(metadata.Value))
+		for key, value := range metadata.Value {
+			entry.Metadata[key] = parseDynamoString(value)
+		}
+	}
+
+	return entry, nil
+}
+
+// verify checks the signature of an audit item and, when the trail has an entry before it,
+// that a chained item follows the given signature of that entry
+func (a *audit) verify(attributes map[string]types.AttributeValue, entry *AuditEntry, previous []byte, hasPrevious bool) error {
+	if len(a.policy.SigningKey) == 0 {
+		return nil
+	}
+
+	entryKey := parseDynamoString(attributes["Entry"])
+	chainedTo := parseDynamoBytes(attributes["PreviousSignature"])
+	valid := hmac.Equal(a.sign(entry, chainedTo), parseDynamoBytes(attributes["Signature"]))
+	if valid && hasPrevious && isChainedEntry(entryKey) {
+		valid = hmac.Equal(chainedTo, previous)
+	}
+	if !valid {
+		return fmt.Errorf("audit entry=%s of persistenceID=%s: %w", entryKey, entry.PersistenceID, ErrAuditTampered)
+	}
+	return nil
+}
+
+// auditAnchors returns the signatures of the newest audit entry of the state stored under the
+// given partition key recorded by the head of its trail and by its state item, when signed
+func (d *DynamoDurableStore) auditAnchors(ctx context.Context, partitionKey string) ([][]byte, error) {
+	if len(d.audit.policy.SigningKey) == 0 {
+		return nil, nil
+	}
+
+	var anchors [][]byte
+	head, hasHead, err := d.auditHead(ctx, partitionKey)
+	if err != nil {
+		return nil, err
+	}
+	if hasHead {
+		anchors = append(anchors, head)
+	}
+
+	ctx = WithConsistentReadContext(ctx)
+	var item *StateItem
+	if _, persistenceID := d.splitPartitionKey(partitionKey); d.sharding.isHot(persistenceID) {
+		item, err = d.getShardedItem(ctx, partitionKey)
+	} else {
+		item, err = d.getStateItem(ctx, partitionKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item != nil && len(item.AuditSignature) > 0 {
+		anchors = append(anchors, item.AuditSignature)
+	}
+	return anchors, nil
+}
+
+// verifyAnchors checks that the trail made of the given entries and signatures holds the entries
+// the anchors point to, so that the removal of the newest entries is detected. With a TTL, the
+// anchors are not checked once the newest entry of the trail has expired, since the entries they
+// point to may have expired as well.
+func (a *audit) verifyAnchors(anchors [][]byte, signatures map[string]bool, entries []*AuditEntry, now time.Time) error {
+	if a.policy.TTL > 0 && (len(entries) == 0 || !entries[len(entries)-1].AuditedAt.Add(a.policy.TTL).After(now)) {
+		return nil
+	}
+	for _, anchor := range anchors {
+		if !signatures[string(anchor)] {
+			return fmt.Errorf("newest entry is missing: %w", ErrAuditTampered)
+		}
+	}
+	return nil
+}
+
+// createAuditTable creates the audit table keyed by PersistenceID and Entry, and enables the
+// expiry of its entries when a TTL is configured
+func (d *DynamoDurableStore) createAuditTable(ctx context.Context) error {
+	_, err := d.client.Load().CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(d.audit.policy.Table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PersistenceID"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("Entry"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("PersistenceID"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("Entry"), KeyType: types.KeyTypeRange},
+		},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create the audit table: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(d.client.Load())
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.audit.policy.Table)}, tableActiveTimeout); err != nil {
+		return fmt.Errorf("failed to wait for the audit table to be active: %w", err)
+	}
+
+	if d.audit.policy.TTL <= 0 {
+		return nil
+	}
+	ttl, err := d.client.Load().DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(d.audit.policy.Table)})
+	if err != nil {
+		return fmt.Errorf("failed to describe the time to live of the audit table: %w", err)
+	}
+	if ttl.TimeToLiveDescription != nil && ttl.TimeToLiveDescription.TimeToLiveStatus != types.TimeToLiveStatusDisabled {
+		return nil
+	}
+	_, err = d.client.Load().UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(d.audit.policy.Table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("ExpiresAt"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable the time to live of the audit table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newAuditedStore creates a store appending signed audit entries to the audit table of a fake endpoint
+func newAuditedStore(t *testing.T, opts ...Option) (*DynamoDurableStore, *fakeDynamoDB) {
+	t.Helper()
+	store, fake := newFakeStore(t, append(opts, WithAudit(AuditPolicy{Table: "audit", SigningKey: []byte("secret")}))...)
+	fake.createTable("audit", "PersistenceID", "Entry")
+	return store, fake
+}
+
+// auditEntries returns the entries of the audit table of the fake endpoint oldest first,
+// leaving out the heads of the trails
+func auditEntries(fake *fakeDynamoDB) []attributes {
+	var entries []attributes
+	for _, item := range fake.items("audit") {
+		if item["Entry"]["S"] != auditHeadEntry {
+			entries = append(entries, item)
+		}
+	}
+	return entries
+}
+
+func TestAuditTrailDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(fake *fakeDynamoDB)
+		wantErr error
+	}{
+		{name: "untouched", tamper: func(*fakeDynamoDB) {}},
+		{
+			name:   "oldest entry expired",
+			tamper: func(fake *fakeDynamoDB) { fake.deleteItem("audit", auditEntries(fake)[0]) },
+		},
+		{
+			name:    "entry removed",
+			tamper:  func(fake *fakeDynamoDB) { fake.deleteItem("audit", auditEntries(fake)[1]) },
+			wantErr: ErrAuditTampered,
+		},
+		{
+			name: "entry modified",
+			tamper: func(fake *fakeDynamoDB) {
+				entry := auditEntries(fake)[1]
+				entry["Actor"] = map[string]any{"S": "mallory"}
+				fake.putItem("audit", entry)
+			},
+			wantErr: ErrAuditTampered,
+		},
+		{
+			name: "newest entry removed",
+			tamper: func(fake *fakeDynamoDB) {
+				entries := auditEntries(fake)
+				fake.deleteItem("audit", entries[len(entries)-1])
+			},
+			wantErr: ErrAuditTampered,
+		},
+		{
+			name: "newest entry removed with the head rewound",
+			tamper: func(fake *fakeDynamoDB) {
+				entries := auditEntries(fake)
+				fake.deleteItem("audit", entries[len(entries)-1])
+				fake.putItem("audit", attributes{
+					"PersistenceID": entries[0]["PersistenceID"],
+					"Entry":         {"S": auditHeadEntry},
+					"Signature":     entries[len(entries)-2]["Signature"],
+				})
+			},
+			wantErr: ErrAuditTampered,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, fake := newAuditedStore(t)
+			ctx := WithAuditContext(context.Background(), "alice", nil)
+			for _, version := range []uint64{1, 2, 2, 3} {
+				if err := store.WriteState(ctx, testState(t, "order-1", version)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			test.tamper(fake)
+
+			entries, err := store.AuditTrail(ctx, "order-1")
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("AuditTrail error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(auditEntries(fake)); len(entries) != want {
+				t.Fatalf("AuditTrail returned %d entries, want %d", len(entries), want)
+			}
+		})
+	}
+}
+
+func TestAuditRewrittenVersionAppendsEntry(t *testing.T) {
+	store, _ := newAuditedStore(t)
+	ctx := context.Background()
+	for _, version := range []uint64{1, 2, 2} {
+		if err := store.WriteState(ctx, testState(t, "order-1", version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := store.AuditTrail(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []uint64
+	for _, entry := range entries {
+		versions = append(versions, entry.VersionNumber)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 2 {
+		t.Fatalf("audited versions = %v, want [1 2 2]", versions)
+	}
+}
+
+func TestAuditDeletions(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      bool
+		wantEntries int
+	}{
+		{name: "stored state", stored: true, wantEntries: 2},
+		{name: "missing state", stored: false, wantEntries: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, fake := newAuditedStore(t)
+			ctx := context.Background()
+			if test.stored {
+				if err := store.WriteState(ctx, testState(t, "order-1", 1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := store.DeleteState(ctx, "order-1", 1); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(auditEntries(fake)); got != test.wantEntries {
+				t.Fatalf("audit entries = %d, want %d", got, test.wantEntries)
+			}
+		})
+	}
+}
+
+func TestAuditWithIdempotentWrites(t *testing.T) {
+	store, fake := newAuditedStore(t, WithIdempotentWrites())
+	ctx := context.Background()
+	for _, version := range []uint64{1, 2} {
+		if err := store.WriteState(ctx, testState(t, "order-1", version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(auditEntries(fake)); got != 2 {
+		t.Fatalf("audit entries = %d, want 2", got)
+	}
+	if _, ok := fake.items(DefaultTableName)[0]["AuditSignature"]; !ok {
+		t.Fatal("state item has no AuditSignature attribute")
+	}
+	if _, err := store.AuditTrail(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditConcurrentWritesKeepTheTrail(t *testing.T) {
+	store, fake := newAuditedStore(t)
+	ctx := context.Background()
+	if err := store.WriteState(ctx, testState(t, "order-1", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer appends its entry after the head of the trail was read
+	var concurrent error
+	fake.beforeNext("TransactWriteItems", func() {
+		concurrent = store.WriteState(ctx, testState(t, "order-1", 2))
+	})
+	if err := store.WriteState(ctx, testState(t, "order-1", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if concurrent != nil {
+		t.Fatal(concurrent)
+	}
+
+	entries, err := store.AuditTrail(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("AuditTrail returned %d entries, want 3", len(entries))
+	}
+}
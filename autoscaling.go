@@ -0,0 +1,133 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultTargetUtilization is the consumed to provisioned capacity ratio, in percent, the
+// auto scaling policies aim at when none is configured
+const DefaultTargetUtilization = 70
+
+// AutoScaling defines the provisioned capacity of the states table and its global secondary
+// indexes, scaled by Application Auto Scaling between the minimum and the maximum
+type AutoScaling struct {
+	MinReadCapacity  int32
+	MaxReadCapacity  int32
+	MinWriteCapacity int32
+	MaxWriteCapacity int32
+	// TargetUtilization is the consumed to provisioned capacity ratio, in percent, the policies
+	// aim at, DefaultTargetUtilization when zero
+	TargetUtilization float64
+}
+
+// autoScaling provisions the capacity of the tables through Application Auto Scaling
+type autoScaling struct {
+	policy AutoScaling
+	client atomic.Pointer[applicationautoscaling.Client]
+}
+
+// billingMode returns the billing mode of the tables created by EnsureTable
+func (d *DynamoDurableStore) billingMode() types.BillingMode {
+	if d.autoScaling != nil {
+		return types.BillingModeProvisioned
+	}
+	return types.BillingModePayPerRequest
+}
+
+// provisionedThroughput returns the initial capacity of the tables and indexes created by
+// EnsureTable, nil for on-demand tables
+func (d *DynamoDurableStore) provisionedThroughput() *types.ProvisionedThroughput {
+	if d.autoScaling == nil {
+		return nil
+	}
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(int64(d.autoScaling.policy.MinReadCapacity)),
+		WriteCapacityUnits: aws.Int64(int64(d.autoScaling.policy.MinWriteCapacity)),
+	}
+}
+
+// configureAutoScaling registers the states table and its active global secondary indexes as
+// scalable targets and attaches target tracking policies to their read and write capacity.
+// On-demand tables are left as they are: EnsureTable does not switch the billing mode of an
+// existing table. Both calls are idempotent.
+func (d *DynamoDurableStore) configureAutoScaling(ctx context.Context, table *types.TableDescription) error {
+	if tableBillingMode(table) != types.BillingModeProvisioned {
+		d.logger.Warn("skipping the auto scaling of an on-demand table", "table", d.schema.TableName)
+		return nil
+	}
+
+	resource := "table/" + d.schema.TableName
+	if err := d.scaleResource(ctx, resource,
+		autoscalingtypes.ScalableDimensionDynamoDBTableReadCapacityUnits,
+		autoscalingtypes.ScalableDimensionDynamoDBTableWriteCapacityUnits); err != nil {
+		return err
+	}
+
+	statuses := indexStatuses(table)
+	for _, index := range d.globalSecondaryIndexes() {
+		if statuses[aws.ToString(index.IndexName)] != types.IndexStatusActive {
+			continue
+		}
+		if err := d.scaleResource(ctx, resource+"/index/"+aws.ToString(index.IndexName),
+			autoscalingtypes.ScalableDimensionDynamoDBIndexReadCapacityUnits,
+			autoscalingtypes.ScalableDimensionDynamoDBIndexWriteCapacityUnits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleResource configures the auto scaling of the read and write capacity of a table or an index
+func (d *DynamoDurableStore) scaleResource(ctx context.Context, resource string, read, write autoscalingtypes.ScalableDimension) error {
+	policy := d.autoScaling.policy
+	targetUtilization := policy.TargetUtilization
+	if targetUtilization <= 0 {
+		targetUtilization = DefaultTargetUtilization
+	}
+
+	dimensions := []struct {
+		dimension autoscalingtypes.ScalableDimension
+		metric    autoscalingtypes.MetricType
+		min, max  int32
+	}{
+		{read, autoscalingtypes.MetricTypeDynamoDBReadCapacityUtilization, policy.MinReadCapacity, policy.MaxReadCapacity},
+		{write, autoscalingtypes.MetricTypeDynamoDBWriteCapacityUtilization, policy.MinWriteCapacity, policy.MaxWriteCapacity},
+	}
+	for _, dimension := range dimensions {
+		_, err := d.autoScaling.client.Load().RegisterScalableTarget(ctx, &applicationautoscaling.RegisterScalableTargetInput{
+			ServiceNamespace:  autoscalingtypes.ServiceNamespaceDynamodb,
+			ResourceId:        aws.String(resource),
+			ScalableDimension: dimension.dimension,
+			MinCapacity:       aws.Int32(dimension.min),
+			MaxCapacity:       aws.Int32(dimension.max),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register the scalable target=%s %s: %w", resource, dimension.dimension, err)
+		}
+
+		_, err = d.autoScaling.client.Load().PutScalingPolicy(ctx, &applicationautoscaling.PutScalingPolicyInput{
+			PolicyName:        aws.String(fmt.Sprintf("%s-%s", resource, dimension.metric)),
+			ServiceNamespace:  autoscalingtypes.ServiceNamespaceDynamodb,
+			ResourceId:        aws.String(resource),
+			ScalableDimension: dimension.dimension,
+			PolicyType:        autoscalingtypes.PolicyTypeTargetTrackingScaling,
+			TargetTrackingScalingPolicyConfiguration: &autoscalingtypes.TargetTrackingScalingPolicyConfiguration{
+				TargetValue: aws.Float64(targetUtilization),
+				PredefinedMetricSpecification: &autoscalingtypes.PredefinedMetricSpecification{
+					PredefinedMetricType: dimension.metric,
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put the scaling policy of target=%s %s: %w", resource, dimension.dimension, err)
+		}
+	}
+	return nil
+}
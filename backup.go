@@ -0,0 +1,189 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Backup describes an on-demand backup of the states table
+type Backup struct {
+	ARN       string
+	Name      string
+	Status    types.BackupStatus
+	Type      types.BackupType
+	CreatedAt time.Time
+	SizeBytes int64
+}
+
+// ListBackupsOption narrows the backups returned by ListBackups
+type ListBackupsOption func(input *dynamodb.ListBackupsInput)
+
+// CreatedBetween only lists the backups created in the given time range
+func CreatedBetween(from, to time.Time) ListBackupsOption {
+	return func(input *dynamodb.ListBackupsInput) {
+		input.TimeRangeLowerBound = aws.Time(from)
+		input.TimeRangeUpperBound = aws.Time(to)
+	}
+}
+
+// OfBackupType only lists the backups of the given type, user-created backups by default
+func OfBackupType(backupType types.BackupTypeFilter) ListBackupsOption {
+	return func(input *dynamodb.ListBackupsInput) {
+		input.BackupType = backupType
+	}
+}
+
+// RestoreOption configures the restoration of the states table into a new table
+type RestoreOption func(restore *restoreOptions)
+
+type restoreOptions struct {
+	at          *time.Time
+	billingMode types.BillingMode
+}
+
+// AtTime restores the table as it was at the given time instead of the latest restorable time
+func AtTime(at time.Time) RestoreOption {
+	return func(restore *restoreOptions) {
+		restore.at = &at
+	}
+}
+
+// WithRestoredBillingMode overrides the billing mode of the restored table
+func WithRestoredBillingMode(mode types.BillingMode) RestoreOption {
+	return func(restore *restoreOptions) {
+		restore.billingMode = mode
+	}
+}
+
+// EnablePointInTimeRecovery turns on the continuous backups of the states table
+func (d *DynamoDurableStore) EnablePointInTimeRecovery(ctx context.Context) error {
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	_, err := d.client.Load().UpdateContinuousBackups(ctx, &dynamodb.UpdateContinuousBackupsInput{
+		TableName: aws.String(d.schema.TableName),
+		PointInTimeRecoverySpecification: &types.PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable the point in time recovery: %w", err)
+	}
+	return nil
+}
+
+// CreateBackup takes an on-demand backup of the states table with the given name
+func (d *DynamoDurableStore) CreateBackup(ctx context.Context, name string) (*Backup, error) {
+	if err := d.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Load().CreateBackup(ctx, &dynamodb.CreateBackupInput{
+		TableName:  aws.String(d.schema.TableName),
+		BackupName: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the backup=%s: %w", name, err)
+	}
+
+	details := resp.BackupDetails
+	return &Backup{
+		ARN:       aws.ToString(details.BackupArn),
+		Name:      aws.ToString(details.BackupName),
+		Status:    details.BackupStatus,
+		Type:      details.BackupType,
+		CreatedAt: aws.ToTime(details.BackupCreationDateTime),
+		SizeBytes: aws.ToInt64(details.BackupSizeBytes),
+	}, nil
+}
+
+// ListBackups returns the backups of the states table
+func (d *DynamoDurableStore) ListBackups(ctx context.Context, opts ...ListBackupsOption) ([]Backup, error) {
+	if err := d.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	input := &dynamodb.ListBackupsInput{
+		TableName: aws.String(d.schema.TableName),
+	}
+	for _, opt := range opts {
+		opt(input)
+	}
+
+	var backups []Backup
+	for {
+		resp, err := d.client.Load().ListBackups(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the backups: %w", err)
+		}
+
+		for _, summary := range resp.BackupSummaries {
+			backups = append(backups, Backup{
+				ARN:       aws.ToString(summary.BackupArn),
+				Name:      aws.ToString(summary.BackupName),
+				Status:    summary.BackupStatus,
+				Type:      summary.BackupType,
+				CreatedAt: aws.ToTime(summary.BackupCreationDateTime),
+				SizeBytes: aws.ToInt64(summary.BackupSizeBytes),
+			})
+		}
+
+		if resp.LastEvaluatedBackupArn == nil {
+			return backups, nil
+		}
+		input.ExclusiveStartBackupArn = resp.LastEvaluatedBackupArn
+	}
+}
+
+// RestoreBackup restores the given backup into a new table named targetTable.
+// The restored table keeps the secondary indexes of the backup.
+func (d *DynamoDurableStore) RestoreBackup(ctx context.Context, backupARN, targetTable string, opts ...RestoreOption) error {
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	restore := new(restoreOptions)
+	for _, opt := range opts {
+		opt(restore)
+	}
+
+	_, err := d.client.Load().RestoreTableFromBackup(ctx, &dynamodb.RestoreTableFromBackupInput{
+		BackupArn:           aws.String(backupARN),
+		TargetTableName:     aws.String(targetTable),
+		BillingModeOverride: restore.billingMode,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore the backup=%s: %w", backupARN, err)
+	}
+	return nil
+}
+
+// RestoreToPointInTime restores the states table into a new table named targetTable, as it was
+// at the latest restorable time or at the time given with AtTime. Point in time recovery must be enabled.
+func (d *DynamoDurableStore) RestoreToPointInTime(ctx context.Context, targetTable string, opts ...RestoreOption) error {
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	restore := new(restoreOptions)
+	for _, opt := range opts {
+		opt(restore)
+	}
+
+	input := &dynamodb.RestoreTableToPointInTimeInput{
+		SourceTableName:     aws.String(d.schema.TableName),
+		TargetTableName:     aws.String(targetTable),
+		BillingModeOverride: restore.billingMode,
+	}
+	if restore.at != nil {
+		input.RestoreDateTime = restore.at
+	} else {
+		input.UseLatestRestorableTime = aws.Bool(true)
+	}
+
+	if _, err := d.client.Load().RestoreTableToPointInTime(ctx, input); err != nil {
+		return fmt.Errorf("failed to restore the table to a point in time: %w", err)
+	}
+	return nil
+}
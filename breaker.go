@@ -0,0 +1,126 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerPolicy defines when the store stops calling DynamoDB after repeated
+// server-side errors or timeouts, so that callers fail fast during an outage.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed calls opening the circuit
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before probe calls are let through
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of concurrent probe calls allowed while the circuit is half-open.
+	// A successful probe closes the circuit, a failed one opens it again.
+	HalfOpenProbes int
+}
+
+// DefaultCircuitBreakerPolicy is a circuit breaker policy suitable for most deployments
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	FailureThreshold: 20,
+	OpenDuration:     10 * time.Second,
+	HalfOpenProbes:   1,
+}
+
+// circuitState is the state of the circuit breaker
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker tracks the consecutive failures of the DynamoDB calls
+type circuitBreaker struct {
+	policy   CircuitBreakerPolicy
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// allow reports whether a call can be made and whether it is a half-open probe
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.policy.OpenDuration {
+			return false, ErrCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		b.probes = 0
+		fallthrough
+	case circuitHalfOpen:
+		if b.probes >= max(b.policy.HalfOpenProbes, 1) {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// record updates the circuit with the outcome of a call
+func (b *circuitBreaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+	}
+
+	switch {
+	case !failed:
+		if probe || b.state == circuitClosed {
+			b.state = circuitClosed
+			b.failures = 0
+		}
+	case probe:
+		b.open()
+	case b.state == circuitClosed:
+		b.failures++
+		if b.failures >= b.policy.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+// open opens the circuit
+func (b *circuitBreaker) open() {
+	b.state = circuitOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
+
+// isUnavailabilityError reports whether a failed call hints at DynamoDB being unavailable.
+// Client errors such as failed conditions do not count towards opening the circuit.
+func isUnavailabilityError(ctx context.Context, err error) bool {
+	if isServerError(err) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+// guard runs a call through the circuit breaker when it is enabled
+func (d *DynamoDurableStore) guard(ctx context.Context, call func() error) error {
+	if d.breaker == nil {
+		return call()
+	}
+
+	probe, err := d.breaker.allow()
+	if err != nil {
+		return err
+	}
+	err = call()
+	d.breaker.record(probe, err != nil && isUnavailabilityError(ctx, err))
+	return err
+}
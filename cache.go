@@ -0,0 +1,148 @@
+package dynamodb
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// stateCache is a bounded in-memory LRU cache of the latest durable states keyed by
+// partition key. Entries expire after the TTL and are never replaced by an older version.
+type stateCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// cacheEntry is an element of the LRU list
+type cacheEntry struct {
+	key       string
+	state     *egopb.DurableState
+	expiresAt time.Time
+}
+
+// newStateCache creates a cache holding at most size states for at most ttl.
+// A zero ttl keeps the states until they are evicted.
+func newStateCache(size int, ttl time.Duration) *stateCache {
+	return &stateCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// get returns a copy of the state cached under the given partition key
+func (c *stateCache) get(key string) (*egopb.DurableState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return cloneState(entry.state), true
+}
+
+// put caches a copy of the state under the given partition key unless a newer version is already cached
+func (c *stateCache) put(key string, state *egopb.DurableState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{
+		key:       key,
+		state:     cloneState(state),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+
+	if element, ok := c.entries[key]; ok {
+		if element.Value.(*cacheEntry).state.GetVersionNumber() > state.GetVersionNumber() {
+			return
+		}
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate drops the state cached under the given partition key
+func (c *stateCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// invalidateOlder drops the state cached under the given partition key unless it is at least
+// at the given version. A zero version drops the state whatever its version.
+func (c *stateCache) invalidateOlder(key string, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		if version == 0 || element.Value.(*cacheEntry).state.GetVersionNumber() < version {
+			c.remove(element)
+		}
+	}
+}
+
+// remove drops an element of the cache. The lock must be held.
+func (c *stateCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// cloneState copies the state so that cached entries cannot be mutated by callers
+func cloneState(state *egopb.DurableState) *egopb.DurableState {
+	return &egopb.DurableState{
+		PersistenceId:  state.GetPersistenceId(),
+		VersionNumber:  state.GetVersionNumber(),
+		ResultingState: proto.Clone(state.GetResultingState()).(*anypb.Any),
+		Timestamp:      state.GetTimestamp(),
+		Shard:          state.GetShard(),
+	}
+}
+
+// RunCacheInvalidator keeps the read cache, see WithCache, consistent with the writes made by
+// the other nodes of the cluster: it consumes the DynamoDB Stream of the states table, which
+// must be enabled, and evicts the cached states older than the written ones. It covers every
+// tenant and blocks until the context is done. Changes made while it is not running are only
+// caught up with by the cache TTL.
+func (d *DynamoDurableStore) RunCacheInvalidator(ctx context.Context) error {
+	if d.cache == nil {
+		return errors.New("cache is not enabled")
+	}
+
+	// without prefix, the persistence IDs of the changes are the partition keys caching the states
+	return d.subscribe(ctx, "", func(ctx context.Context, change *StateChange) error {
+		version := change.NewVersion
+		if change.Removed {
+			version = 0
+		}
+		d.cache.invalidateOlder(change.PersistenceID, version)
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+)
+
+// callOptions overrides the configuration of the store for the calls made with a context.
+// The persistence.StateStore methods have fixed signatures, so the overrides travel in the context.
+type callOptions struct {
+	consistentRead bool
+	timeout        time.Duration
+}
+
+// callOptionsKey is the context key of the call options
+type callOptionsKey struct{}
+
+// callOptionsFrom returns the call options of the context
+func callOptionsFrom(ctx context.Context) callOptions {
+	options, _ := ctx.Value(callOptionsKey{}).(callOptions)
+	return options
+}
+
+// withCallOptions returns a context carrying the call options of ctx modified by the given function
+func withCallOptions(ctx context.Context, modify func(options *callOptions)) context.Context {
+	options := callOptionsFrom(ctx)
+	modify(&options)
+	return context.WithValue(ctx, callOptionsKey{}, options)
+}
+
+// WithConsistentReadContext returns a context making the GetLatestState calls made with it
+// strongly consistent: they bypass the read cache and DAX and read from the primary region.
+func WithConsistentReadContext(ctx context.Context) context.Context {
+	return withCallOptions(ctx, func(options *callOptions) {
+		options.consistentRead = true
+	})
+}
+
+// WithTimeoutContext returns a context bounding every single DynamoDB call made with it to the
+// given timeout, in place of the timeouts configured with WithTimeout, WithReadTimeout and
+// WithWriteTimeout
+func WithTimeoutContext(ctx context.Context, timeout time.Duration) context.Context {
+	return withCallOptions(ctx, func(options *callOptions) {
+		options.timeout = timeout
+	})
+}
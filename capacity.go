@@ -0,0 +1,83 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ConsumedCapacity is the capacity consumed by a single DynamoDB call
+type ConsumedCapacity struct {
+	Operation          string
+	ReadCapacityUnits  float64
+	WriteCapacityUnits float64
+}
+
+// CapacityCallback receives the capacity consumed by every DynamoDB call. The context is the
+// one of the call, so that values set by the caller, such as an entity ID, can be used to
+// attribute the cost.
+type CapacityCallback func(ctx context.Context, consumed ConsumedCapacity)
+
+// CapacityTracker accumulates the capacity consumed by the calls made with a context
+// returned by WithCapacityTracker. It is safe for concurrent use.
+type CapacityTracker struct {
+	mu    sync.Mutex
+	read  float64
+	write float64
+}
+
+// ReadCapacityUnits returns the read capacity units consumed so far
+func (t *CapacityTracker) ReadCapacityUnits() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.read
+}
+
+// WriteCapacityUnits returns the write capacity units consumed so far
+func (t *CapacityTracker) WriteCapacityUnits() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.write
+}
+
+// add records the capacity consumed by a call
+func (t *CapacityTracker) add(consumed ConsumedCapacity) {
+	t.mu.Lock()
+	t.read += consumed.ReadCapacityUnits
+	t.write += consumed.WriteCapacityUnits
+	t.mu.Unlock()
+}
+
+// capacityTrackerKey is the context key of the CapacityTracker
+type capacityTrackerKey struct{}
+
+// WithCapacityTracker returns a context accumulating into the returned tracker the capacity
+// consumed by the store calls made with it. Consumed capacity is only reported by DynamoDB
+// when WithMetrics or WithConsumedCapacity is set.
+func WithCapacityTracker(ctx context.Context) (context.Context, *CapacityTracker) {
+	tracker := new(CapacityTracker)
+	return context.WithValue(ctx, capacityTrackerKey{}, tracker), tracker
+}
+
+// observeConsumedCapacity forwards the consumed capacity returned by DynamoDB, when requested,
+// to the metrics, the capacity callback and the tracker of the context
+func (d *DynamoDurableStore) observeConsumedCapacity(ctx context.Context, operation string, consumed *types.ConsumedCapacity) {
+	if consumed == nil || consumed.CapacityUnits == nil {
+		return
+	}
+	d.metrics.ObserveConsumedCapacity(operation, *consumed.CapacityUnits)
+
+	report := ConsumedCapacity{Operation: operation}
+	if writeOperations[operation] {
+		report.WriteCapacityUnits = *consumed.CapacityUnits
+	} else {
+		report.ReadCapacityUnits = *consumed.CapacityUnits
+	}
+	if d.capacityCallback != nil {
+		d.capacityCallback(ctx, report)
+	}
+	if tracker, ok := ctx.Value(capacityTrackerKey{}).(*CapacityTracker); ok {
+		tracker.add(report)
+	}
+}
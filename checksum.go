@@ -0,0 +1,51 @@
+package dynamodb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Checksum defines the algorithm of the integrity checksum of the state payload
+type Checksum string
+
+const (
+	// NoChecksum stores no checksum
+	NoChecksum Checksum = ""
+	// CRC32CChecksum checksums the state payload with CRC-32C
+	CRC32CChecksum Checksum = "crc32c"
+	// SHA256Checksum checksums the state payload with SHA-256
+	SHA256Checksum Checksum = "sha256"
+)
+
+// crc32cTable is the Castagnoli polynomial table
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum computes the checksum of the payload with the given algorithm, prefixed by the
+// algorithm so that the checksums stay verifiable after a change of algorithm
+func checksum(algorithm Checksum, payload []byte) (string, error) {
+	switch algorithm {
+	case CRC32CChecksum:
+		return fmt.Sprintf("%s:%08x", algorithm, crc32.Checksum(payload, crc32cTable)), nil
+	case SHA256Checksum:
+		sum := sha256.Sum256(payload)
+		return string(algorithm) + ":" + hex.EncodeToString(sum[:]), nil
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm=%s", algorithm)
+	}
+}
+
+// verifyChecksum verifies the payload against the checksum stored with it
+func verifyChecksum(stored string, payload []byte) error {
+	algorithm, _, _ := strings.Cut(stored, ":")
+	computed, err := checksum(Checksum(algorithm), payload)
+	if err != nil {
+		return withClass(ErrCorruptPayload, err)
+	}
+	if computed != stored {
+		return fmt.Errorf("payload checksum=%s does not match the stored checksum=%s: %w", computed, stored, ErrCorruptPayload)
+	}
+	return nil
+}
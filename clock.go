@@ -0,0 +1,21 @@
+package dynamodb
+
+import "time"
+
+// Clock tells the time to the store. The store reads it for the timestamps it writes, such as
+// UpdatedAt and the DeletedAt of tombstones, and for its retention cutoffs. Latencies, rate
+// limits and circuit breaker cooldowns always use the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock reading the system time
+type systemClock struct{}
+
+// enforce interface implementation
+var _ Clock = systemClock{}
+
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
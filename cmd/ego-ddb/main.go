@@ -0,0 +1,295 @@
+// Command ego-ddb manages and inspects the DynamoDB table of the ego durable store.
+//
+//	ego-ddb [flags] create-table
+//	ego-ddb [flags] describe
+//	ego-ddb [flags] get <persistenceID>
+//	ego-ddb [flags] delete <persistenceID>
+//	ego-ddb [flags] find <prefix>
+//	ego-ddb [flags] export <bucket> <prefix>
+//	ego-ddb [flags] import <bucket> <prefix>
+//	ego-ddb [flags] stats
+//
+// The AWS credentials and region are resolved from the environment like the AWS CLI does.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+)
+
+const usage = `usage: ego-ddb [flags] <command> [arguments]
+
+commands:
+  create-table                 create the states table and its indexes
+  describe                     describe the states table
+  get <persistenceID>          print the latest state of an entity
+  delete <persistenceID>       delete the latest state of an entity
+  find <prefix>                list the persistence IDs starting with a prefix
+  export <bucket> <prefix>     export the states to S3
+  import <bucket> <prefix>     import the states exported to S3
+  stats                        count the states per shard and find the largest items
+
+flags:
+`
+
+// findPageSize is the number of persistence IDs fetched per page by the find command
+const findPageSize = 100
+
+// cli holds the command line flags
+type cli struct {
+	table       string
+	region      string
+	endpoint    string
+	tenant      string
+	descriptors string
+}
+
+func main() {
+	var c cli
+	flag.StringVar(&c.table, "table", dynamodb.DefaultTableName, "name of the states table")
+	flag.StringVar(&c.region, "region", "", "AWS region, defaults to the region of the environment")
+	flag.StringVar(&c.endpoint, "endpoint", "", "DynamoDB endpoint, for instance of DynamoDB Local")
+	flag.StringVar(&c.tenant, "tenant", "", "tenant ID of a multi-tenant table")
+	flag.StringVar(&c.descriptors, "descriptors", "", "file descriptor set of the state messages, used to print states as JSON")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := c.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "ego-ddb:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the given command
+func (c cli) run(ctx context.Context, command string, args []string) error {
+	store, resolver, err := c.newStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "create-table":
+		if err := expectArgs(args); err != nil {
+			return err
+		}
+		if err := store.EnsureTable(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("table %s is active\n", c.table)
+		return nil
+	case "describe":
+		if err := expectArgs(args); err != nil {
+			return err
+		}
+		health, err := store.Health(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(health)
+	case "get":
+		if err := expectArgs(args, "persistenceID"); err != nil {
+			return err
+		}
+		state, err := store.GetLatestState(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			return fmt.Errorf("persistenceID=%s not found", args[0])
+		}
+		output, err := protojson.MarshalOptions{Multiline: true, Resolver: resolver}.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("failed to print the state, see the -descriptors flag: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	case "delete":
+		if err := expectArgs(args, "persistenceID"); err != nil {
+			return err
+		}
+		state, err := store.GetLatestState(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			return fmt.Errorf("persistenceID=%s not found", args[0])
+		}
+		if err := store.DeleteState(ctx, args[0], state.GetVersionNumber()); err != nil {
+			return err
+		}
+		fmt.Printf("deleted version %d of %s\n", state.GetVersionNumber(), args[0])
+		return nil
+	case "find":
+		if err := expectArgs(args, "prefix"); err != nil {
+			return err
+		}
+		var pageToken string
+		for {
+			persistenceIDs, nextPageToken, err := store.FindPersistenceIDs(ctx, args[0], findPageSize, pageToken)
+			if err != nil {
+				return err
+			}
+			for _, persistenceID := range persistenceIDs {
+				fmt.Println(persistenceID)
+			}
+			if nextPageToken == "" {
+				return nil
+			}
+			pageToken = nextPageToken
+		}
+	case "export":
+		if err := expectArgs(args, "bucket", "prefix"); err != nil {
+			return err
+		}
+		count, err := store.ExportToS3(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("exported %d states to s3://%s/%s\n", count, args[0], args[1])
+		return nil
+	case "import":
+		if err := expectArgs(args, "bucket", "prefix"); err != nil {
+			return err
+		}
+		count, err := store.ImportFromS3(ctx, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d states from s3://%s/%s\n", count, args[0], args[1])
+		return nil
+	case "stats":
+		if err := expectArgs(args); err != nil {
+			return err
+		}
+		stats, err := store.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(stats)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// newStore creates the durable store configured by the flags, along with the resolver of the
+// state message types
+func (c cli) newStore(ctx context.Context) (*dynamodb.DynamoDurableStore, *protoregistry.Types, error) {
+	var loadOptions []func(*config.LoadOptions) error
+	if c.region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(c.region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+
+	schema := dynamodb.DefaultSchema
+	schema.TableName = c.table
+	opts := []dynamodb.Option{
+		dynamodb.WithAWSConfig(cfg),
+		dynamodb.WithSchema(schema),
+	}
+	if c.endpoint != "" {
+		opts = append(opts, dynamodb.WithEndpoint(c.endpoint))
+	}
+	if c.tenant != "" {
+		opts = append(opts, dynamodb.WithTenant(c.tenant))
+	}
+
+	resolver := protoregistry.GlobalTypes
+	if c.descriptors != "" {
+		if resolver, err = loadDescriptors(c.descriptors); err != nil {
+			return nil, nil, err
+		}
+	}
+	return dynamodb.NewStateStore(opts...), resolver, nil
+}
+
+// loadDescriptors registers the messages of the given file descriptor set, as generated by
+// protoc --include_imports --descriptor_set_out
+func loadDescriptors(path string) (*protoregistry.Types, error) {
+	bytea, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the descriptors: %w", err)
+	}
+	set := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(bytea, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the descriptors: %w", err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the descriptors: %w", err)
+	}
+
+	types := new(protoregistry.Types)
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		err = registerMessages(types, file.Messages())
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register the descriptors: %w", err)
+	}
+	return types, nil
+}
+
+// registerMessages registers the given messages and their nested messages
+func registerMessages(types *protoregistry.Types, messages protoreflect.MessageDescriptors) error {
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		if err := types.RegisterMessage(dynamicpb.NewMessageType(message)); err != nil {
+			return err
+		}
+		if err := registerMessages(types, message.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectArgs verifies the command got exactly the named arguments
+func expectArgs(args []string, names ...string) error {
+	if len(args) != len(names) {
+		if len(names) == 0 {
+			return errors.New("unexpected arguments")
+		}
+		return fmt.Errorf("expected arguments: %v", names)
+	}
+	return nil
+}
+
+// printJSON prints the value as indented JSON
+func printJSON(value any) error {
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	return nil
+}
@@ -0,0 +1,195 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultCompactionBatchSize is the number of states examined per compaction pass when none is configured
+const DefaultCompactionBatchSize = 1000
+
+// CompactorConfig configures a Compactor
+type CompactorConfig struct {
+	// Interval is the delay between two passes of Run
+	Interval time.Duration
+	// BatchSize is the number of states examined per pass, DefaultCompactionBatchSize when zero.
+	// A pass resumes the sweep of the table where the previous one stopped.
+	BatchSize int
+	// MaxRate is the maximum number of states compacted per second, zero means unlimited
+	MaxRate float64
+}
+
+// CompactionProgress reports the work done by a Compactor since it was created
+type CompactionProgress struct {
+	// StatesScanned is the number of states examined
+	StatesScanned int64
+	// VersionsDeleted is the number of versions deleted from the history table
+	VersionsDeleted int64
+	// TombstonesReaped is the number of expired soft-deleted states hard-deleted
+	TombstonesReaped int64
+	// Sweeps is the number of completed sweeps of the whole table
+	Sweeps int64
+	// LastPass is the end time of the last pass
+	LastPass time.Time
+}
+
+// Compactor keeps the history table within its retention and hard-deletes the expired tombstones,
+// see WithHistory and WithSoftDelete, in bounded and rate-limited passes over the states table.
+// It combines TrimHistory and ReapTombstones into a single sweep of the table.
+type Compactor struct {
+	store   *DynamoDurableStore
+	config  CompactorConfig
+	limiter *rateLimiter
+
+	// mu serializes the passes
+	mu     sync.Mutex
+	cursor map[string]types.AttributeValue
+
+	progressMu sync.Mutex
+	progress   CompactionProgress
+}
+
+// NewCompactor creates a Compactor of the given store
+func NewCompactor(store *DynamoDurableStore, config CompactorConfig) *Compactor {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultCompactionBatchSize
+	}
+	compactor := &Compactor{store: store, config: config}
+	if config.MaxRate > 0 {
+		compactor.limiter = newRateLimiter(config.MaxRate)
+	}
+	return compactor
+}
+
+// Progress returns the work done by the compactor so far
+func (c *Compactor) Progress() CompactionProgress {
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+	return c.progress
+}
+
+// Run compacts the table every interval until the context is done
+func (c *Compactor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Compact(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact runs a single pass examining at most BatchSize states, on demand
+func (c *Compactor) Compact(ctx context.Context) error {
+	d := c.store
+	if d.history == nil && d.softDelete <= 0 {
+		return errors.New("neither history nor soft delete is enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.update(func(progress *CompactionProgress) { progress.LastPass = c.store.clock.Now() })
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber, #DeletedAt"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber", "DeletedAt"),
+		ExclusiveStartKey:        c.cursor,
+		ReturnConsumedCapacity:   d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, prefix)
+
+	latest := newLatestVersions()
+	for remaining := c.config.BatchSize; remaining > 0; {
+		input.Limit = aws.Int32(int32(remaining))
+
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationCompact, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationCompact, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			if err := c.compactState(ctx, latest, attributes); err != nil {
+				return err
+			}
+		}
+		// DynamoDB counts the items evaluated rather than returned against the limit
+		remaining -= int(resp.ScannedCount)
+
+		c.cursor = resp.LastEvaluatedKey
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+		if resp.LastEvaluatedKey == nil {
+			c.update(func(progress *CompactionProgress) { progress.Sweeps++ })
+			return nil
+		}
+	}
+	return nil
+}
+
+// compactState trims the history of a scanned state and reaps it when it is an expired tombstone
+func (c *Compactor) compactState(ctx context.Context, latest *latestVersions, attributes map[string]types.AttributeValue) error {
+	d := c.store
+	if c.limiter != nil {
+		if _, err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	var trimmed, reaped int64
+	defer c.update(func(progress *CompactionProgress) {
+		progress.StatesScanned++
+		progress.VersionsDeleted += trimmed
+		progress.TombstonesReaped += reaped
+	})
+
+	if d.history != nil {
+		count, err := d.trimStateHistory(ctx, latest, attributes)
+		trimmed = int64(count)
+		if err != nil {
+			return err
+		}
+	}
+
+	deletedAt := parseDynamoOptionalInt64(attributes[d.schema.attr("DeletedAt")])
+	if d.softDelete > 0 && deletedAt > 0 && deletedAt <= d.clock.Now().Add(-d.softDelete).UnixMilli() {
+		deleted, err := d.reapTombstone(ctx, attributes)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			reaped = 1
+		}
+	}
+	return nil
+}
+
+// update applies the given change to the progress
+func (c *Compactor) update(change func(progress *CompactionProgress)) {
+	c.progressMu.Lock()
+	change(&c.progress)
+	c.progressMu.Unlock()
+}
@@ -0,0 +1,95 @@
+package dynamodb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression defines the codec used to compress the state payload
+type Compression string
+
+const (
+	// NoCompression stores the state payload as is
+	NoCompression Compression = ""
+	// GzipCompression compresses the state payload with gzip
+	GzipCompression Compression = "gzip"
+	// ZstdCompression compresses the state payload with zstd
+	ZstdCompression Compression = "zstd"
+)
+
+// zstd encoders and decoders are safe for concurrent use with EncodeAll and DecodeAll.
+// gzip writers and readers are not, they are pooled since each one allocates large buffers.
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	gzipReaders sync.Pool
+)
+
+// compress compresses the payload with the given codec
+func compress(codec Compression, payload []byte) ([]byte, error) {
+	switch codec {
+	case NoCompression:
+		return payload, nil
+	case GzipCompression:
+		var buf bytes.Buffer
+		buf.Grow(len(payload) / 2)
+		writer := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(writer)
+		writer.Reset(&buf)
+		if _, err := writer.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case ZstdCompression:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(payload, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec=%s", codec)
+	}
+}
+
+// decompress decompresses the payload given the codec it was compressed with
+func decompress(codec Compression, payload []byte) ([]byte, error) {
+	switch codec {
+	case NoCompression:
+		return payload, nil
+	case GzipCompression:
+		reader, err := gzipReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReaders.Put(reader)
+		return io.ReadAll(reader)
+	case ZstdCompression:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(payload, nil)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec=%s", codec)
+	}
+}
+
+// gzipReader returns a pooled gzip reader reset to read the given payload
+func gzipReader(payload io.Reader) (*gzip.Reader, error) {
+	if reader, ok := gzipReaders.Get().(*gzip.Reader); ok {
+		if err := reader.Reset(payload); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	return gzip.NewReader(payload)
+}
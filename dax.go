@@ -0,0 +1,55 @@
+package dynamodb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// daxCooldown is how long reads bypass DAX after it failed to serve a request
+const daxCooldown = 30 * time.Second
+
+// ItemReader is the subset of the DynamoDB API used to read state items.
+// It is implemented by *dynamodb.Client and by the DAX client of github.com/aws/aws-dax-go-v2.
+type ItemReader interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+// enforce interface implementation
+var _ ItemReader = (*dynamodb.Client)(nil)
+
+// daxReader routes reads through a DAX cluster and keeps track of its availability
+type daxReader struct {
+	client           ItemReader
+	unavailableUntil atomic.Int64
+}
+
+// available reports whether reads can be routed through DAX
+func (r *daxReader) available() bool {
+	return time.Now().UnixNano() >= r.unavailableUntil.Load()
+}
+
+// markUnavailable makes reads bypass DAX for the cooldown period
+func (r *daxReader) markUnavailable() {
+	r.unavailableUntil.Store(time.Now().Add(daxCooldown).UnixNano())
+}
+
+// getItem reads an item through DAX when configured and available, and falls back to
+// DynamoDB when DAX fails to serve the request. Strongly consistent reads go straight to
+// the primary region.
+func (d *DynamoDurableStore) getItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if aws.ToBool(input.ConsistentRead) {
+		return d.client.Load().GetItem(ctx, input)
+	}
+	if d.dax != nil && d.dax.available() {
+		resp, err := d.dax.client.GetItem(ctx, input)
+		if err == nil || ctx.Err() != nil {
+			return resp, err
+		}
+		d.dax.markUnavailable()
+	}
+	return d.regionalGetItem(ctx, input)
+}
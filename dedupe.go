@@ -0,0 +1,108 @@
+package dynamodb
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// writeDedupe remembers the last version written for the most recently written partition keys,
+// so that writes repeating that version can be skipped. It is a bounded LRU.
+type writeDedupe struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// dedupeEntry is an element of the LRU list
+type dedupeEntry struct {
+	key     string
+	version uint64
+}
+
+// newWriteDedupe creates a write dedupe remembering at most size partition keys
+func newWriteDedupe(size int) *writeDedupe {
+	return &writeDedupe{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// written tells whether the given version is the last one written for the partition key.
+// It is false when write dedupe is disabled.
+func (w *writeDedupe) written(key string, version uint64) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	element, ok := w.entries[key]
+	if !ok || element.Value.(*dedupeEntry).version != version {
+		return false
+	}
+	w.order.MoveToFront(element)
+	return true
+}
+
+// record remembers the version as the last one written for the partition key
+func (w *writeDedupe) record(key string, version uint64) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if element, ok := w.entries[key]; ok {
+		element.Value.(*dedupeEntry).version = version
+		w.order.MoveToFront(element)
+		return
+	}
+
+	w.entries[key] = w.order.PushFront(&dedupeEntry{key: key, version: version})
+	for w.order.Len() > w.size {
+		element := w.order.Back()
+		w.order.Remove(element)
+		delete(w.entries, element.Value.(*dedupeEntry).key)
+	}
+}
+
+// forget drops the version remembered for the partition key
+func (w *writeDedupe) forget(key string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if element, ok := w.entries[key]; ok {
+		w.order.Remove(element)
+		delete(w.entries, key)
+	}
+}
+
+// dedupeCondition makes the put skip an item already stored at the same version, for the
+// partition keys the write dedupe does not remember
+func (d *DynamoDurableStore) dedupeCondition(input *dynamodb.PutItemInput, version uint64) {
+	if d.dedupe == nil {
+		return
+	}
+	input.ConditionExpression = aws.String("attribute_not_exists(#PersistenceID) OR #VersionNumber <> :version")
+	input.ExpressionAttributeNames = d.schema.names("PersistenceID", "VersionNumber")
+	input.ExpressionAttributeValues = map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: strconv.FormatUint(version, 10)},
+	}
+}
+
+// isDuplicateWrite tells whether the put failed because the version was already stored
+func (d *DynamoDurableStore) isDuplicateWrite(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	return d.dedupe != nil && errors.As(err, &conditionFailed)
+}
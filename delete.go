@@ -0,0 +1,341 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DeleteState deletes the durable state of the given persistence ID provided its stored version
+// is expectedVersion, otherwise it returns ErrVersionConflict so that a state concurrently
+// updated by another node is never deleted based on stale information. In soft-delete mode the
+// item is kept with a DeletedAt tombstone attribute: it is no longer returned by the store
+// until it is restored, and it is hard-deleted by the tombstone reaper once the retention
+// window has elapsed. Deleting a missing state is a no-op.
+func (d *DynamoDurableStore) DeleteState(ctx context.Context, persistenceID string, expectedVersion uint64) error {
+	partitionKey, err := d.partitionKey(ctx, persistenceID)
+	if err != nil {
+		return err
+	}
+
+	// a buffered write must not resurrect the state after its deletion
+	if err := d.Flush(ctx); err != nil {
+		return err
+	}
+
+	keys, comparison, exists := []string{partitionKey}, "=", false
+	if d.sharding.isHot(persistenceID) {
+		// the expected version is checked against the newest of the write shards, the older
+		// versions stored under the other keys are deleted as well
+		item, err := d.getShardedItem(ctx, partitionKey)
+		if err != nil {
+			return err
+		}
+		if item == nil || item.DeletedAt > 0 {
+			return nil
+		}
+		if item.VersionNumber != expectedVersion {
+			return fmt.Errorf("failed to delete version=%d of persistenceID=%s: %w", expectedVersion, persistenceID, ErrVersionConflict)
+		}
+		keys, comparison, exists = d.sharding.keys(partitionKey), "<=", true
+	}
+
+	// the audit entry is appended along with the deletion of the unsharded key
+	err = d.appendAudited(ctx, partitionKey, expectedVersion, OperationDeleteState, func(audit *auditAppend) error {
+		return d.deleteKey(ctx, partitionKey, comparison, expectedVersion, audit, exists)
+	})
+	for _, key := range keys[1:] {
+		if err != nil {
+			break
+		}
+		err = d.deleteKey(ctx, key, comparison, expectedVersion, nil, exists)
+	}
+
+	d.invalidateCache(partitionKey)
+	d.dedupe.forget(partitionKey)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("failed to delete version=%d of persistenceID=%s: %w", expectedVersion, persistenceID, ErrVersionConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete the state of persistenceID=%s from the dynamodb: %w", persistenceID, err)
+	}
+	return nil
+}
+
+// deleteKey deletes, or soft-deletes, the item stored under the given partition key provided
+// its version compares to expectedVersion with the given comparison operator. The audit entry,
+// if any, is appended within the same transaction, and only when an item is deleted unless
+// exists reports the state was read beforehand. A soft-deleted item anchors the entry.
+func (d *DynamoDurableStore) deleteKey(ctx context.Context, partitionKey, comparison string, expectedVersion uint64, audit *auditAppend, exists bool) error {
+	key := d.schema.key(partitionKey)
+	version := &types.AttributeValueMemberN{Value: strconv.FormatUint(expectedVersion, 10)}
+
+	if d.softDelete <= 0 {
+		input := &dynamodb.DeleteItemInput{
+			TableName:                aws.String(d.schema.TableName),
+			Key:                      key,
+			ConditionExpression:      aws.String("attribute_not_exists(#PersistenceID) OR #VersionNumber " + comparison + " :version"),
+			ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": version,
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		}
+		if audit != nil {
+			deletion := &types.Delete{
+				TableName:                 input.TableName,
+				Key:                       input.Key,
+				ConditionExpression:       input.ConditionExpression,
+				ExpressionAttributeNames:  input.ExpressionAttributeNames,
+				ExpressionAttributeValues: input.ExpressionAttributeValues,
+			}
+			if !exists {
+				// a missing state fails the condition, so that no audit entry is appended
+				deletion.ConditionExpression = aws.String("#VersionNumber " + comparison + " :version")
+				deletion.ExpressionAttributeNames = d.schema.names("VersionNumber")
+				deletion.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+			}
+			err := d.deleteTransaction(ctx, types.TransactWriteItem{Delete: deletion}, audit)
+			var conditionFailed *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionFailed) && conditionFailed.Item == nil && !exists {
+				return nil
+			}
+			return err
+		}
+		return d.execute(ctx, OperationDeleteState, func(ctx context.Context) error {
+			resp, err := d.client.Load().DeleteItem(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationDeleteState, resp.ConsumedCapacity)
+			}
+			return err
+		})
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(d.schema.TableName),
+		Key:                      key,
+		UpdateExpression:         aws.String("SET #DeletedAt = :now"),
+		ConditionExpression:      aws.String("attribute_exists(#PersistenceID) AND attribute_not_exists(#DeletedAt) AND #VersionNumber " + comparison + " :version"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "DeletedAt", "VersionNumber"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(d.clock.Now().UnixMilli(), 10)},
+			":version": version,
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		ReturnConsumedCapacity:              d.returnConsumedCapacity(),
+	}
+	var err error
+	if audit != nil {
+		update := &types.Update{
+			TableName:                           input.TableName,
+			Key:                                 input.Key,
+			UpdateExpression:                    input.UpdateExpression,
+			ConditionExpression:                 input.ConditionExpression,
+			ExpressionAttributeNames:            input.ExpressionAttributeNames,
+			ExpressionAttributeValues:           input.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: input.ReturnValuesOnConditionCheckFailure,
+		}
+		if audit.signature != nil {
+			update.UpdateExpression = aws.String("SET #DeletedAt = :now, #AuditSignature = :signature")
+			update.ExpressionAttributeNames = d.schema.names("PersistenceID", "DeletedAt", "VersionNumber", "AuditSignature")
+			update.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":now":       input.ExpressionAttributeValues[":now"],
+				":version":   version,
+				":signature": &types.AttributeValueMemberB{Value: audit.signature},
+			}
+		}
+		err = d.deleteTransaction(ctx, types.TransactWriteItem{Update: update}, audit)
+	} else {
+		err = d.execute(ctx, OperationDeleteState, func(ctx context.Context) error {
+			resp, err := d.client.Load().UpdateItem(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationDeleteState, resp.ConsumedCapacity)
+			}
+			return err
+		})
+	}
+	// a missing or already deleted state is not a conflict
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) && (conditionFailed.Item == nil || conditionFailed.Item[d.schema.attr("DeletedAt")] != nil) {
+		return nil
+	}
+	return err
+}
+
+// deleteTransaction runs the deletion along with its audit entry in a transaction. A failed
+// condition of the deletion is reported as a ConditionalCheckFailedException, as outside of
+// a transaction.
+func (d *DynamoDurableStore) deleteTransaction(ctx context.Context, deletion types.TransactWriteItem, audit *auditAppend) error {
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems:          append([]types.TransactWriteItem{deletion}, audit.actions()...),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	err := d.execute(ctx, OperationDeleteState, func(ctx context.Context) error {
+		resp, err := d.client.Load().TransactWriteItems(ctx, input)
+		if err == nil {
+			for index := range resp.ConsumedCapacity {
+				d.observeConsumedCapacity(ctx, OperationDeleteState, &resp.ConsumedCapacity[index])
+			}
+		}
+		return err
+	})
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+		aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return &types.ConditionalCheckFailedException{
+			Message: canceled.CancellationReasons[0].Message,
+			Item:    canceled.CancellationReasons[0].Item,
+		}
+	}
+	return err
+}
+
+// Restore undoes the soft deletion of the state of the given persistence ID.
+// It is a no-op when the state is not deleted.
+func (d *DynamoDurableStore) Restore(ctx context.Context, persistenceID string) error {
+	partitionKey, err := d.partitionKey(ctx, persistenceID)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{partitionKey}
+	if d.sharding.isHot(persistenceID) {
+		keys = d.sharding.keys(partitionKey)
+	}
+
+	for _, key := range keys {
+		err := d.execute(ctx, OperationRestoreState, func(ctx context.Context) error {
+			resp, err := d.client.Load().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                aws.String(d.schema.TableName),
+				Key:                      d.schema.key(key),
+				UpdateExpression:         aws.String("REMOVE #DeletedAt"),
+				ConditionExpression:      aws.String("attribute_exists(#DeletedAt)"),
+				ExpressionAttributeNames: d.schema.names("DeletedAt"),
+				ReturnConsumedCapacity:   d.returnConsumedCapacity(),
+			})
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationRestoreState, resp.ConsumedCapacity)
+			}
+			return err
+		})
+
+		var conditionFailed *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &conditionFailed) {
+			return fmt.Errorf("failed to restore the state of persistenceID=%s: %w", persistenceID, err)
+		}
+	}
+	return nil
+}
+
+// ReapTombstones hard-deletes the soft-deleted states whose retention window has elapsed and
+// returns how many were deleted. A state restored or rewritten in the meantime is kept.
+func (d *DynamoDurableStore) ReapTombstones(ctx context.Context) (int, error) {
+	if d.softDelete <= 0 {
+		return 0, errors.New("soft delete is not enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := strconv.FormatInt(d.clock.Now().Add(-d.softDelete).UnixMilli(), 10)
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		ProjectionExpression:     aws.String("#PersistenceID, #DeletedAt"),
+		FilterExpression:         aws.String("#DeletedAt <= :cutoff"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "DeletedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cutoff": &types.AttributeValueMemberN{Value: cutoff},
+		},
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if prefix != "" {
+		input.FilterExpression = aws.String("#DeletedAt <= :cutoff AND begins_with(#PersistenceID, :tenant)")
+		input.ExpressionAttributeValues[":tenant"] = &types.AttributeValueMemberS{Value: prefix}
+	}
+
+	reaped := 0
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationReapTombstones, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationReapTombstones, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return reaped, fmt.Errorf("failed to scan the tombstones: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			deleted, err := d.reapTombstone(ctx, attributes)
+			if err != nil {
+				return reaped, err
+			}
+			if deleted {
+				reaped++
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return reaped, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// reapTombstone hard-deletes a tombstone unless it has been restored or rewritten since it was scanned
+func (d *DynamoDurableStore) reapTombstone(ctx context.Context, attributes map[string]types.AttributeValue) (bool, error) {
+	err := d.execute(ctx, OperationReapTombstones, func(ctx context.Context) error {
+		resp, err := d.client.Load().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                aws.String(d.schema.TableName),
+			Key:                      d.schema.key(parseDynamoString(attributes[d.schema.PartitionKey])),
+			ConditionExpression:      aws.String("#DeletedAt = :deletedAt"),
+			ExpressionAttributeNames: d.schema.names("DeletedAt"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":deletedAt": attributes[d.schema.attr("DeletedAt")],
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationReapTombstones, resp.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &conditionFailed):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to delete the tombstone=%s: %w", parseDynamoString(attributes[d.schema.PartitionKey]), err)
+	default:
+		return true, nil
+	}
+}
+
+// RunTombstoneReaper calls ReapTombstones every interval until the context is done
+func (d *DynamoDurableStore) RunTombstoneReaper(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.ReapTombstones(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
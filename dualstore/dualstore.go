@@ -0,0 +1,156 @@
+// Package dualstore provides a persistence.StateStore writing to two state stores side by
+// side, to migrate from another state store to DynamoDB, or back, without a maintenance window.
+package dualstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"github.com/tochemey/ego/v3/persistence"
+	"google.golang.org/protobuf/proto"
+)
+
+// Divergence kinds reported to the Metrics hooks
+const (
+	// DivergenceWriteFailed is reported when the secondary store failed a write the primary store applied
+	DivergenceWriteFailed = "write_failed"
+	// DivergenceMissing is reported when a state is found in one store only
+	DivergenceMissing = "missing"
+	// DivergenceVersion is reported when the stores hold different versions of a state
+	DivergenceVersion = "version"
+	// DivergenceContent is reported when the stores hold different states under the same version
+	DivergenceContent = "content"
+)
+
+// Metrics records how the two stores diverge, to decide when the cutover is safe
+type Metrics interface {
+	// IncDivergence counts a divergence of the given kind
+	IncDivergence(kind string)
+	// IncFallback counts a read served by the secondary store
+	IncFallback()
+}
+
+// noopMetrics discards the measurements
+type noopMetrics struct{}
+
+func (noopMetrics) IncDivergence(string) {}
+func (noopMetrics) IncFallback()         {}
+
+// Config configures the dual store
+type Config struct {
+	// Metrics records the divergences and fallbacks, none are recorded when nil
+	Metrics Metrics
+	// StrictWrites fails the writes the secondary store failed. By default they only count
+	// a divergence, the primary store being the source of truth.
+	StrictWrites bool
+	// CompareReads also reads the secondary store on every read served by the primary one
+	// and counts the divergences, at the cost of a second read
+	CompareReads bool
+}
+
+// dualStore writes to two stores and reads from the primary one
+type dualStore struct {
+	primary   persistence.StateStore
+	secondary persistence.StateStore
+	config    Config
+}
+
+// enforce interface implementation
+var _ persistence.StateStore = (*dualStore)(nil)
+
+// New returns a StateStore writing every state to primary then secondary, and reading from
+// primary with a fallback to secondary when primary fails or does not have the state yet.
+// Start with the old store as primary, swap the stores once the new one has caught up, then
+// drop the dual store once the divergence metrics stay at zero.
+func New(primary, secondary persistence.StateStore, config Config) persistence.StateStore {
+	if config.Metrics == nil {
+		config.Metrics = noopMetrics{}
+	}
+	return &dualStore{
+		primary:   primary,
+		secondary: secondary,
+		config:    config,
+	}
+}
+
+// Connect connects both stores
+func (s *dualStore) Connect(ctx context.Context) error {
+	if err := s.primary.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect the primary store: %w", err)
+	}
+	if err := s.secondary.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect the secondary store: %w", err)
+	}
+	return nil
+}
+
+// Disconnect disconnects both stores
+func (s *dualStore) Disconnect(ctx context.Context) error {
+	return errors.Join(s.primary.Disconnect(ctx), s.secondary.Disconnect(ctx))
+}
+
+// Ping pings both stores
+func (s *dualStore) Ping(ctx context.Context) error {
+	return errors.Join(s.primary.Ping(ctx), s.secondary.Ping(ctx))
+}
+
+// WriteState persists the state in the primary store, then in the secondary store
+func (s *dualStore) WriteState(ctx context.Context, state *egopb.DurableState) error {
+	if err := s.primary.WriteState(ctx, state); err != nil {
+		return err
+	}
+	if err := s.secondary.WriteState(ctx, state); err != nil {
+		s.config.Metrics.IncDivergence(DivergenceWriteFailed)
+		if s.config.StrictWrites {
+			return fmt.Errorf("failed to write the state into the secondary store: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetLatestState fetches the state from the primary store, falling back to the secondary store
+// when the primary store fails or does not have the state
+func (s *dualStore) GetLatestState(ctx context.Context, persistenceID string) (*egopb.DurableState, error) {
+	state, err := s.primary.GetLatestState(ctx, persistenceID)
+	if err == nil && state != nil && !s.config.CompareReads {
+		return state, nil
+	}
+
+	secondary, secondaryErr := s.secondary.GetLatestState(ctx, persistenceID)
+	switch {
+	case err != nil:
+		if secondaryErr != nil {
+			return nil, errors.Join(err, secondaryErr)
+		}
+		s.config.Metrics.IncFallback()
+		return secondary, nil
+	case state == nil:
+		if secondaryErr != nil {
+			return nil, secondaryErr
+		}
+		if secondary != nil {
+			s.config.Metrics.IncDivergence(DivergenceMissing)
+			s.config.Metrics.IncFallback()
+		}
+		return secondary, nil
+	}
+
+	if secondaryErr == nil {
+		s.compare(state, secondary)
+	}
+	return state, nil
+}
+
+// compare counts the divergence between the states read from both stores
+func (s *dualStore) compare(primary, secondary *egopb.DurableState) {
+	switch {
+	case secondary == nil:
+		s.config.Metrics.IncDivergence(DivergenceMissing)
+	case primary.GetVersionNumber() != secondary.GetVersionNumber():
+		s.config.Metrics.IncDivergence(DivergenceVersion)
+	case !proto.Equal(primary.GetResultingState(), secondary.GetResultingState()):
+		s.config.Metrics.IncDivergence(DivergenceContent)
+	}
+}
@@ -3,132 +3,306 @@ package dynamodb
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
 
 	"github.com/tochemey/ego/v3/egopb"
 	"github.com/tochemey/ego/v3/persistence"
-	"google.golang.org/protobuf/proto"
 )
 
-// No sort key is needed because we are only storing the latest state
+// No sort key is needed because we are only storing the latest state.
+// The struct tags name the attributes of the default schema, see Schema.
 type StateItem struct {
-	PersistenceID string // Partition key
-	VersionNumber uint64
-	StatePayload  []byte
-	StateManifest string
-	Timestamp     int64
-	ShardNumber   uint64
-}
+	PersistenceID string `dynamodbav:"PersistenceID"` // Partition key, prefixed by the tenant ID in multi-tenant mode
+	VersionNumber uint64 `dynamodbav:"VersionNumber"`
+	StatePayload  []byte `dynamodbav:"StatePayload,omitempty"`
+	StateManifest string `dynamodbav:"StateManifest"`
+	Timestamp     int64  `dynamodbav:"Timestamp"`
+	ShardNumber   uint64 `dynamodbav:"ShardNumber"`
+	PayloadCodec  string `dynamodbav:"PayloadCodec,omitempty"`  // Compression codec of StatePayload, absent when uncompressed
+	PayloadBucket string `dynamodbav:"PayloadBucket,omitempty"` // S3 bucket of an offloaded payload
+	PayloadKey    string `dynamodbav:"PayloadKey,omitempty"`    // S3 key of an offloaded payload
+	PayloadETag   string `dynamodbav:"PayloadETag,omitempty"`   // S3 ETag of an offloaded payload
 
-const (
-	tableName = "states_store"
-)
+	PayloadChecksum string `dynamodbav:"PayloadChecksum,omitempty"` // Algorithm-prefixed checksum of the marshalled state, absent when checksums are disabled
+
+	EncryptedDataKey []byte `dynamodbav:"EncryptedDataKey,omitempty"` // KMS wrapped data key of an encrypted payload
+
+	DeletedAt int64 `dynamodbav:"DeletedAt,omitempty"` // Unix milliseconds of the soft deletion, zero when the state is live
+
+	UpdatedAt int64 `dynamodbav:"UpdatedAt,omitempty"` // Unix milliseconds of the write, set by the store and indexed by UpdatedIndex
+
+	RecordVersion uint32 `dynamodbav:"RecordVersion"` // Layout version the item was stored with, see CurrentRecordVersion. Items are always written with the current one.
+
+	AuditSignature []byte `dynamodbav:"AuditSignature,omitempty"` // Signature of the newest audit entry of the state, anchoring its audit trail, absent when the entries are not signed
+
+	shardKey string // Partition key the item is written under when its persistence ID is hot
+}
 
 // DynamoDurableStore implements the DurableStore interface
 // and helps persist states in a DynamoDB
 type DynamoDurableStore struct {
-	client *dynamodb.Client
+	// the clients are replaced by Connect while calls may still be reading them
+	client   atomic.Pointer[dynamodb.Client]
+	streams  atomic.Pointer[dynamodbstreams.Client]
+	s3       atomic.Pointer[s3.Client]
+	metrics  Metrics
+	capacity bool
+	retry    RetryPolicy
+
+	compression        Compression
+	compressionMinSize int
+	overflow           *s3Overflow
+	encryption         *kmsEncryption
+	outboxTable        string
+	dax                *daxReader
+	cache              *stateCache
+	serializer         Serializer
+	tenant             TenantResolver
+	timeouts           Timeouts
+	health             *healthCache
+	writeBehind        *writeBehind
+	idempotentWrites   bool
+	softDelete         time.Duration
+	awsConfig          *aws.Config
+	endpoint           string
+	logger             Logger
+	slowThreshold      time.Duration
+	regions            *multiRegion
+	localRegion        string
+	readLimiter        *rateLimiter
+	writeLimiter       *rateLimiter
+	breaker            *circuitBreaker
+	history            *history
+	readOnly           bool
+	schema             Schema
+	capacityCallback   CapacityCallback
+	apiOptions         []func(*middleware.Stack) error
+	sharding           *writeSharding
+	validation         *ValidationPolicy
+	autoScaling        *autoScaling
+	dedupe             *writeDedupe
+	checksum           Checksum
+	warmUpConfig       *WarmUp
+	clock              Clock
+	hedging            *hedging
+	audit              *audit
+	priority           *priorityQueue
+	snapshots          *SnapshotPolicy
+
+	// mu serializes Connect and Disconnect, connected is read without it by every call
+	mu        sync.Mutex
+	connected atomic.Bool
 }
 
 // enforce interface implementation
 var _ persistence.StateStore = (*DynamoDurableStore)(nil)
 
-func NewStateStore() *DynamoDurableStore {
-	cfg, err := config.LoadDefaultConfig(context.Background())
-	if err != nil {
-		return nil
+// NewStateStore creates a DynamoDB durable store configured by the given options. The AWS
+// clients are created by Connect, or lazily by the first call needing them.
+func NewStateStore(opts ...Option) *DynamoDurableStore {
+	store := &DynamoDurableStore{
+		metrics:       noopMetrics{},
+		logger:        noopLogger{},
+		slowThreshold: DefaultSlowOperationThreshold,
+		retry:         DefaultRetryPolicy,
+		serializer:    NewProtoSerializer(nil),
+		health:        &healthCache{ttl: DefaultHealthCacheTTL},
+		schema:        DefaultSchema,
+		clock:         systemClock{},
 	}
 
-	return &DynamoDurableStore{
-		client: dynamodb.NewFromConfig(cfg),
+	for _, opt := range opts {
+		opt(store)
+	}
+	if store.regions != nil && slices.Contains(append([]string{store.regions.primary}, store.regions.replicas...), store.localRegion) {
+		store.regions.local = store.localRegion
+	}
+	if queueMetrics, ok := store.metrics.(QueueMetrics); ok && store.priority != nil {
+		store.priority.observe = queueMetrics.ObserveQueueDepth
 	}
+	return store
 }
 
-// Connect connects to the journal store
-// No connection is needed because the client is stateless
-func (d DynamoDurableStore) Connect(ctx context.Context) error {
-	return nil
+// Ping verifies the states table exists and is active.
+// The result is cached for a short time, see WithHealthCacheTTL.
+func (d *DynamoDurableStore) Ping(ctx context.Context) error {
+	_, err := d.Health(ctx)
+	return err
 }
 
-// Disconnect disconnect the journal store
-// There is no need to disconnect because the client is stateless
-func (DynamoDurableStore) Disconnect(ctx context.Context) error {
-	return nil
-}
+// WriteState persist durable state for a given persistenceID.
+func (d *DynamoDurableStore) WriteState(ctx context.Context, state *egopb.DurableState) error {
+	if err := d.checkWritable(OperationWriteState); err != nil {
+		return err
+	}
+	if d.dedupe != nil {
+		partitionKey, err := d.partitionKey(ctx, state.GetPersistenceId())
+		if err != nil {
+			return err
+		}
+		if d.dedupe.written(partitionKey, state.GetVersionNumber()) {
+			return nil
+		}
+	}
 
-// Ping verifies a connection to the database is still alive, establishing a connection if necessary.
-// There is no need to ping because the client is stateless
-func (d DynamoDurableStore) Ping(ctx context.Context) error {
-	_, err := d.client.ListTables(ctx, &dynamodb.ListTablesInput{})
+	item, err := d.newStateItem(ctx, state, OperationWriteState)
 	if err != nil {
-		return fmt.Errorf("failed to fetch tables in the dynamodb: %w", err)
+		return err
 	}
-	return nil
-}
-
-// WriteState persist durable state for a given persistenceID.
-func (d DynamoDurableStore) WriteState(ctx context.Context, state *egopb.DurableState) error {
-
-	bytea, _ := proto.Marshal(state.GetResultingState())
-	manifest := string(state.GetResultingState().ProtoReflect().Descriptor().FullName())
 
-	// Define the item to upsert
-	item := map[string]types.AttributeValue{
-		"PersistenceID": &types.AttributeValueMemberS{Value: state.GetPersistenceId()}, // Partition key
-		"StatePayload":  &types.AttributeValueMemberB{Value: bytea},
-		"StateManifest": &types.AttributeValueMemberS{Value: manifest},
-		"Timestamp":     &types.AttributeValueMemberS{Value: string(state.GetTimestamp())},
+	if d.writeBehind != nil {
+		d.updateCache(item.PersistenceID, state)
+		if err := d.writeStateBehind(ctx, item); err != nil {
+			return err
+		}
+		d.dedupe.record(item.PersistenceID, item.VersionNumber)
+		return nil
+	}
+	if d.sharding.isHot(state.GetPersistenceId()) {
+		item.shardKey = d.sharding.shardKey(item.PersistenceID, item.VersionNumber)
 	}
 
-	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item:      item,
-	})
+	if d.idempotentWrites || d.history != nil || d.audit != nil {
+		err = d.writeStateTransaction(ctx, item)
+	} else {
+		input := &dynamodb.PutItemInput{
+			TableName:              aws.String(d.schema.TableName),
+			Item:                   d.stateAttributes(item),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		}
+		d.dedupeCondition(input, item.VersionNumber)
+		err = d.execute(ctx, OperationWriteState, func(ctx context.Context) error {
+			resp, err := d.client.Load().PutItem(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationWriteState, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if d.isDuplicateWrite(err) {
+			err = nil
+		}
+	}
 	if err != nil {
+		d.invalidateCache(item.PersistenceID)
+		d.dedupe.forget(item.PersistenceID)
 		return fmt.Errorf("failed to upsert state into the dynamodb: %w", err)
 	}
 
+	d.dedupe.record(item.PersistenceID, item.VersionNumber)
+	d.updateCache(item.PersistenceID, state)
+	d.trimAfterWrite(ctx, item)
 	return nil
 }
 
 // GetLatestState fetches the latest durable state
-func (d DynamoDurableStore) GetLatestState(ctx context.Context, persistenceID string) (*egopb.DurableState, error) {
-	// Get criteria
-	key := map[string]types.AttributeValue{
-		"PersistenceID": &types.AttributeValueMemberS{Value: persistenceID},
+func (d *DynamoDurableStore) GetLatestState(ctx context.Context, persistenceID string) (*egopb.DurableState, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
 	}
+	partitionKey := prefix + persistenceID
 
-	// Perform the GetItem operation
-	resp, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key:       key,
-	})
+	if d.cache != nil && !callOptionsFrom(ctx).consistentRead {
+		if state, ok := d.cache.get(partitionKey); ok {
+			return state, nil
+		}
+	}
+	if item, ok := d.bufferedItem(partitionKey); ok {
+		return d.toDurableState(ctx, item, prefix, OperationGetLatestState)
+	}
+
+	var item *StateItem
+	if d.sharding.isHot(persistenceID) {
+		item, err = d.getShardedItem(ctx, partitionKey)
+	} else {
+		item, err = d.getStateItem(ctx, partitionKey)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch the latest state from the dynamodb: %w", err)
+		return nil, err
 	}
 
 	// Check if item exists
-	if resp.Item == nil {
+	if item == nil || item.DeletedAt > 0 {
 		return nil, nil
 	}
 
+	state, err := d.toDurableState(ctx, item, prefix, OperationGetLatestState)
+	if err != nil {
+		return nil, err
+	}
+
+	d.updateCache(partitionKey, state)
+	return state, nil
+}
+
+// newStateItem builds the item to persist for the given durable state, recording the size of
+// its payload under the given operation
+func (d *DynamoDurableStore) newStateItem(ctx context.Context, state *egopb.DurableState, operation string) (*StateItem, error) {
+	// encoding the payload may call S3 and KMS
+	if err := d.ensureConnected(ctx); err != nil {
+		return nil, err
+	}
+	partitionKey, err := d.partitionKey(ctx, state.GetPersistenceId())
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, bytea, err := d.serializer.Marshal(state.GetResultingState())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the durable state: %w", err)
+	}
+	if err := d.validateState(state, manifest); err != nil {
+		return nil, err
+	}
+	d.metrics.ObserveItemSize(operation, len(bytea))
+
 	item := &StateItem{
-		PersistenceID: persistenceID,
-		VersionNumber: parseDynamoUint64(resp.Item["VersionNumber"]),
-		StatePayload:  resp.Item["StatePayload"].(*types.AttributeValueMemberB).Value,
-		StateManifest: resp.Item["StateManifest"].(*types.AttributeValueMemberS).Value,
-		Timestamp:     parseDynamoInt64(resp.Item["Timestamp"]),
-		ShardNumber:   parseDynamoUint64(resp.Item["ShardNumber"]),
+		PersistenceID: partitionKey,
+		VersionNumber: state.GetVersionNumber(),
+		StateManifest: manifest,
+		Timestamp:     state.GetTimestamp(),
+		ShardNumber:   state.GetShard(),
+		UpdatedAt:     d.clock.Now().UnixMilli(),
+		RecordVersion: CurrentRecordVersion,
+	}
+	if err := d.encodePayload(ctx, item, bytea); err != nil {
+		return nil, err
 	}
+	if err := d.validateItemSize(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// toDurableState decodes the payload of the item and converts it into a durable state.
+// tenantPrefix is the partition key prefix of the tenant the item must belong to.
+func (d *DynamoDurableStore) toDurableState(ctx context.Context, item *StateItem, tenantPrefix string, operation string) (*egopb.DurableState, error) {
+	persistenceID, err := persistenceIDOf(tenantPrefix, item.PersistenceID)
+	if err != nil {
+		return nil, err
+	}
+	persistenceID = d.sharding.unshard(persistenceID)
+
+	payload, err := d.decodePayload(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	d.metrics.ObserveItemSize(operation, len(payload))
 
 	// unmarshal the event and the state
-	state, err := toProto(item.StateManifest, item.StatePayload)
+	state, err := d.serializer.Unmarshal(item.StateManifest, payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal the durable state: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal the durable state: %w", withClass(ErrCorruptPayload, err))
 	}
 
 	return &egopb.DurableState{
@@ -139,3 +313,67 @@ func (d DynamoDurableStore) GetLatestState(ctx context.Context, persistenceID st
 		Shard:          item.ShardNumber,
 	}, nil
 }
+
+// updateCache caches the state stored under the given partition key when the read cache is enabled
+func (d *DynamoDurableStore) updateCache(partitionKey string, state *egopb.DurableState) {
+	if d.cache != nil {
+		d.cache.put(partitionKey, state)
+	}
+}
+
+// invalidateCache drops the state cached under the given partition key when the read cache is enabled
+func (d *DynamoDurableStore) invalidateCache(partitionKey string) {
+	if d.cache != nil {
+		d.cache.invalidate(partitionKey)
+	}
+}
+
+// execute runs a DynamoDB call for the given operation, retrying it according to the retry policy
+func (d *DynamoDurableStore) execute(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	if err := d.checkWritable(operation); err != nil {
+		return err
+	}
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err := d.attempt(ctx, operation, call)
+		if err == nil || !d.retry.shouldRetry(attempt, err) {
+			return classifyError(err)
+		}
+		delay := d.retry.backoff(attempt)
+		d.logger.Warn("retrying dynamodb call", "operation", operation, "attempt", attempt, "delay", delay, "error", err)
+		if sleep(ctx, delay) != nil {
+			return classifyError(err)
+		}
+	}
+}
+
+// attempt runs a single DynamoDB call for the given operation within its timeout and records its metrics
+func (d *DynamoDurableStore) attempt(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	if err := d.waitForCapacity(ctx, operation); err != nil {
+		return err
+	}
+
+	callCtx, cancel := d.withOperationTimeout(ctx, operation)
+	defer cancel()
+
+	start := time.Now()
+	err := d.guard(ctx, func() error { return call(callCtx) })
+	duration := time.Since(start)
+	d.logAttempt(operation, duration, err)
+	d.metrics.ObserveLatency(operation, duration)
+	d.metrics.IncRequests(operation, err != nil)
+	if isThrottlingError(err) {
+		d.metrics.IncThrottled(operation)
+	}
+	return err
+}
+
+// returnConsumedCapacity tells DynamoDB whether to report the consumed capacity of a call
+func (d *DynamoDurableStore) returnConsumedCapacity() types.ReturnConsumedCapacity {
+	if d.capacity {
+		return types.ReturnConsumedCapacityTotal
+	}
+	return types.ReturnConsumedCapacityNone
+}
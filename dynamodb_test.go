@@ -0,0 +1,73 @@
+package dynamodb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// benchmarkStores are the store configurations the hot paths are benchmarked with
+var benchmarkStores = []struct {
+	name    string
+	options []Option
+}{
+	{name: "uncompressed"},
+	{name: "gzip", options: []Option{WithCompression(GzipCompression, 1024)}},
+}
+
+// benchmarkState returns a durable state with a compressible payload of a few kilobytes
+func benchmarkState(b *testing.B) *egopb.DurableState {
+	b.Helper()
+	state, err := anypb.New(wrapperspb.String(strings.Repeat("order line ", 400)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &egopb.DurableState{PersistenceId: "order-1", VersionNumber: 1, ResultingState: state}
+}
+
+func BenchmarkWriteState(b *testing.B) {
+	for _, bench := range benchmarkStores {
+		b.Run(bench.name, func(b *testing.B) {
+			store, _ := newFakeStore(b, bench.options...)
+			state := benchmarkState(b)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				state.VersionNumber = uint64(i + 1)
+				if err := store.WriteState(ctx, state); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetLatestState(b *testing.B) {
+	for _, bench := range benchmarkStores {
+		b.Run(bench.name, func(b *testing.B) {
+			store, _ := newFakeStore(b, bench.options...)
+			ctx := context.Background()
+			if err := store.WriteState(ctx, benchmarkState(b)); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				state, err := store.GetLatestState(ctx, "order-1")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if state == nil {
+					b.Fatal("state not found")
+				}
+			}
+		})
+	}
+}
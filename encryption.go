@@ -0,0 +1,133 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// KMSKey is the KMS key wrapping the data keys of a state, along with the encryption context
+// bound to them. KMS only unwraps a data key given the same encryption context.
+type KMSKey struct {
+	ARN               string
+	EncryptionContext map[string]string
+}
+
+// encryptionContextKey is the key of the partition key in the default KMS encryption context
+const encryptionContextKey = "PersistenceID"
+
+// KMSKeyResolver chooses the KMS key of the state of the given persistence ID. tenantID is empty
+// unless multi-tenancy is enabled. It must return the same key and encryption context every time
+// it is called for a persistence ID, both when the state is written and when it is read back.
+type KMSKeyResolver func(ctx context.Context, tenantID, persistenceID string) (KMSKey, error)
+
+// kmsEncryption implements envelope encryption of the state payload: every write
+// encrypts the payload with a fresh AES-256 data key generated by KMS, and the data key,
+// wrapped by the KMS key, is stored alongside the payload.
+type kmsEncryption struct {
+	client   atomic.Pointer[kms.Client]
+	keyARN   string
+	resolver KMSKeyResolver
+}
+
+// encryptionKey returns the KMS key of the state item, along with the additional authenticated
+// data binding its encrypted payload to its partition key and version. A payload copied to
+// another version, persistence ID or tenant fails to decrypt. Without a resolver, the partition
+// key is also the KMS encryption context of the data key. The items stored before this binding
+// was introduced are opened without it.
+func (d *DynamoDurableStore) encryptionKey(ctx context.Context, item *StateItem) (KMSKey, []byte, error) {
+	partitionKey := d.basePartitionKey(item.PersistenceID)
+	bound := item.RecordVersion >= encryptionBindingRecordVersion
+
+	var aad []byte
+	if bound {
+		aad = []byte(partitionKey + "/" + strconv.FormatUint(item.VersionNumber, 10))
+	}
+
+	if d.encryption.resolver == nil {
+		key := KMSKey{ARN: d.encryption.keyARN}
+		if bound {
+			key.EncryptionContext = map[string]string{encryptionContextKey: partitionKey}
+		}
+		return key, aad, nil
+	}
+
+	var tenantID string
+	persistenceID := partitionKey
+	if d.tenant != nil {
+		tenantID, persistenceID, _ = strings.Cut(partitionKey, tenantSeparator)
+	}
+	key, err := d.encryption.resolver(ctx, tenantID, persistenceID)
+	if err != nil {
+		return KMSKey{}, nil, fmt.Errorf("failed to resolve the kms key of persistenceID=%s: %w", persistenceID, err)
+	}
+	return key, aad, nil
+}
+
+// encrypt seals the plaintext and the additional authenticated data with a new data key and
+// returns the ciphertext prefixed with its nonce, and the wrapped data key
+func (e *kmsEncryption) encrypt(ctx context.Context, key KMSKey, plaintext, aad []byte) (ciphertext, wrappedKey []byte, err error) {
+	dataKey, err := e.client.Load().GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(key.ARN),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: key.EncryptionContext,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate the data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey.Plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), dataKey.CiphertextBlob, nil
+}
+
+// decrypt unwraps the data key with KMS and opens the ciphertext sealed with the given
+// additional authenticated data
+func (e *kmsEncryption) decrypt(ctx context.Context, key KMSKey, ciphertext, wrappedKey, aad []byte) ([]byte, error) {
+	dataKey, err := e.client.Load().Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    wrappedKey,
+		KeyId:             aws.String(key.ARN),
+		EncryptionContext: key.EncryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted payload is shorter than its nonce")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, aad)
+}
+
+// newAEAD creates the AES-GCM cipher for the given data key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,70 @@
+package dynamodb
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrTableNotFound is returned when the states table, or another table used by the store, does not exist
+	ErrTableNotFound = errors.New("dynamodb table not found")
+	// ErrThrottled is returned when DynamoDB kept rejecting a call because the throughput was exceeded
+	ErrThrottled = errors.New("dynamodb throughput exceeded")
+	// ErrItemTooLarge is returned when a state item exceeds the DynamoDB item size limit
+	ErrItemTooLarge = errors.New("dynamodb item too large")
+	// ErrVersionConflict is returned when the stored version of a state is not the expected one
+	ErrVersionConflict = errors.New("state version conflict")
+	// ErrCorruptPayload is returned when a stored payload cannot be decoded
+	ErrCorruptPayload = errors.New("corrupt state payload")
+	// ErrCircuitOpen is returned without calling DynamoDB while the circuit breaker is open
+	ErrCircuitOpen = errors.New("dynamodb circuit breaker is open")
+	// ErrReadOnly is returned by the operations modifying the table when the store is read-only
+	ErrReadOnly = errors.New("dynamodb state store is read-only")
+	// ErrInvalidState is returned when a state fails the validation made before writing it
+	ErrInvalidState = errors.New("invalid durable state")
+	// ErrAuditTampered is returned when an audit entry does not match its signature
+	ErrAuditTampered = errors.New("audit entry tampered")
+)
+
+// storeError tags an error with the class of failure it belongs to. Both the class and the
+// original error, for instance an AWS SDK error, can be matched with errors.Is and errors.As.
+type storeError struct {
+	class error
+	err   error
+}
+
+func (e *storeError) Error() string {
+	return e.class.Error() + ": " + e.err.Error()
+}
+
+func (e *storeError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// withClass tags the given error with a class of failure
+func withClass(class, err error) error {
+	return &storeError{class: class, err: err}
+}
+
+// classifyError tags the error returned by a DynamoDB call with the class of failure it belongs to
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &notFound):
+		return withClass(ErrTableNotFound, err)
+	case isThrottlingError(err):
+		return withClass(ErrThrottled, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException" && strings.Contains(apiErr.ErrorMessage(), "Item size"):
+		return withClass(ErrItemTooLarge, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,175 @@
+package dynamodb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// exportPartSize is the size above which an export part object is closed and a new one started
+const exportPartSize = 16 * 1024 * 1024
+
+// ExportToS3 writes a logical backup of the states table into the given S3 bucket, as
+// newline-delimited JSON objects named <prefix>part-<n>.ndjson, one StateItem per line.
+// Items are exported as stored: encrypted, compressed and S3-offloaded payloads are kept as is.
+// It returns the number of exported items.
+func (d *DynamoDurableStore) ExportToS3(ctx context.Context, bucket, prefix string) (int, error) {
+	if err := d.ensureConnected(ctx); err != nil {
+		return 0, err
+	}
+	tenant, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(d.schema.TableName),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, tenant)
+
+	var (
+		part     bytes.Buffer
+		encoder  = json.NewEncoder(&part)
+		parts    int
+		exported int
+	)
+	upload := func() error {
+		key := fmt.Sprintf("%spart-%05d.ndjson", prefix, parts)
+		if _, err := d.s3.Load().PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(part.Bytes()),
+		}); err != nil {
+			return fmt.Errorf("failed to upload the export part=%s: %w", key, err)
+		}
+		parts++
+		part.Reset()
+		return nil
+	}
+
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationExport, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationExport, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return exported, fmt.Errorf("failed to scan the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return exported, err
+			}
+			if err := encoder.Encode(item); err != nil {
+				return exported, fmt.Errorf("failed to encode the item: %w", err)
+			}
+			exported++
+		}
+
+		if part.Len() >= exportPartSize {
+			if err := upload(); err != nil {
+				return exported, err
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+
+	if part.Len() > 0 {
+		if err := upload(); err != nil {
+			return exported, err
+		}
+	}
+	return exported, nil
+}
+
+// ImportFromS3 loads into the states table every item of the export parts found under the
+// given S3 prefix, overwriting the items with the same partition key. It returns the number
+// of imported items.
+func (d *DynamoDurableStore) ImportFromS3(ctx context.Context, bucket, prefix string) (int, error) {
+	if err := d.ensureConnected(ctx); err != nil {
+		return 0, err
+	}
+	imported := 0
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		resp, err := d.s3.Load().ListObjectsV2(ctx, input)
+		if err != nil {
+			return imported, fmt.Errorf("failed to list the export parts: %w", err)
+		}
+
+		for _, object := range resp.Contents {
+			count, err := d.importPart(ctx, bucket, aws.ToString(object.Key))
+			imported += count
+			if err != nil {
+				return imported, err
+			}
+		}
+
+		if !aws.ToBool(resp.IsTruncated) {
+			return imported, nil
+		}
+		input.ContinuationToken = resp.NextContinuationToken
+	}
+}
+
+// importPart loads the items of a single export part
+func (d *DynamoDurableStore) importPart(ctx context.Context, bucket, key string) (int, error) {
+	resp, err := d.s3.Load().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to download the export part=%s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	imported := 0
+	batch := make([]*StateItem, 0, maxBatchWriteItems)
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		item := new(StateItem)
+		err := decoder.Decode(item)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return imported, fmt.Errorf("failed to decode the export part=%s: %w", key, err)
+		}
+		if err == nil {
+			batch = append(batch, item)
+		}
+
+		if len(batch) == maxBatchWriteItems || (errors.Is(err, io.EOF) && len(batch) > 0) {
+			if err := d.batchWrite(ctx, OperationImport, batch); err != nil {
+				return imported, fmt.Errorf("failed to import the items of part=%s: %w", key, err)
+			}
+			for _, item := range batch {
+				d.invalidateCache(item.PersistenceID)
+			}
+			imported += len(batch)
+			batch = batch[:0]
+		}
+
+		if errors.Is(err, io.EOF) {
+			return imported, nil
+		}
+	}
+}
@@ -0,0 +1,640 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// attributes is an item in the wire format of the DynamoDB JSON protocol
+type attributes map[string]map[string]any
+
+// fakeTable is a table of the fake DynamoDB endpoint
+type fakeTable struct {
+	partitionKey string
+	sortKey      string
+	items        map[string]attributes
+}
+
+// fakeDynamoDB is an in-memory DynamoDB endpoint speaking the JSON protocol of the service.
+// It serves the calls the store makes on its tables: single item reads and writes, batch and
+// transactional writes, updates made of SET assignments, and queries on the primary key.
+// Condition expressions are only
+// evaluated when they are attribute_not_exists checks or comparisons of an attribute, alone or
+// joined by OR, other conditions always pass. Transactions honor their client request token. The
+// endpoint also stands in for KMS, handing out data keys wrapped as is.
+type fakeDynamoDB struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+	tokens map[string]string
+	lost   map[string]int
+	before map[string]func()
+	server *httptest.Server
+}
+
+// newFakeDynamoDB starts a fake DynamoDB endpoint closed at the end of the test
+func newFakeDynamoDB(t testing.TB) *fakeDynamoDB {
+	t.Helper()
+	fake := &fakeDynamoDB{
+		tables: make(map[string]*fakeTable),
+		tokens: make(map[string]string),
+		lost:   make(map[string]int),
+		before: make(map[string]func()),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+// createTable creates a table keyed by the given partition key and optional sort key
+func (f *fakeDynamoDB) createTable(name, partitionKey, sortKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tables[name] = &fakeTable{partitionKey: partitionKey, sortKey: sortKey, items: make(map[string]attributes)}
+}
+
+// loseResponse makes the next call of the given operation succeed without a response: the call
+// is applied, then held until the client gives up on it
+func (f *fakeDynamoDB) loseResponse(operation string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lost[operation]++
+}
+
+// beforeNext runs the given function before the next call of the given operation is handled,
+// outside the lock of the endpoint so that the function can make calls of its own
+func (f *fakeDynamoDB) beforeNext(operation string, fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.before[operation] = fn
+}
+
+// putItem stores an item in the given table as is
+func (f *fakeDynamoDB) putItem(table string, item attributes) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tables[table].items[f.tables[table].key(item)] = item
+}
+
+// items returns the items of the given table in key order
+func (f *fakeDynamoDB) items(table string) []attributes {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.tables[table]
+	items := make([]attributes, 0, len(t.items))
+	for _, item := range t.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return t.key(items[i]) < t.key(items[j]) })
+	return items
+}
+
+// deleteItem deletes an item from the given table
+func (f *fakeDynamoDB) deleteItem(table string, item attributes) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tables[table].items, f.tables[table].key(item))
+}
+
+// itemCount returns the number of items of the given table
+func (f *fakeDynamoDB) itemCount(table string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tables[table].items)
+}
+
+// options returns the options connecting a store to the fake endpoint
+func (f *fakeDynamoDB) options() []Option {
+	return []Option{
+		WithAWSConfig(aws.Config{
+			Region:       "us-east-1",
+			Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+			BaseEndpoint: aws.String(f.server.URL),
+		}),
+		WithEndpoint(f.server.URL),
+	}
+}
+
+// newFakeStore creates a store connected to a fake endpoint serving the states table
+func newFakeStore(t testing.TB, opts ...Option) (*DynamoDurableStore, *fakeDynamoDB) {
+	t.Helper()
+	fake := newFakeDynamoDB(t)
+	fake.createTable(DefaultTableName, DefaultSchema.PartitionKey, "")
+	store := NewStateStore(append(fake.options(), opts...)...)
+	if err := store.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Disconnect(context.Background()) })
+	return store, fake
+}
+
+// testState returns a durable state of the given persistence ID and version
+func testState(t testing.TB, persistenceID string, version uint64) *egopb.DurableState {
+	t.Helper()
+	state, err := anypb.New(wrapperspb.String(fmt.Sprintf("%s-%d", persistenceID, version)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &egopb.DurableState{
+		PersistenceId:  persistenceID,
+		VersionNumber:  version,
+		ResultingState: state,
+		Timestamp:      int64(version),
+	}
+}
+
+// fakeError is an error response of the fake endpoint
+type fakeError struct {
+	kind    string
+	message string
+	reasons []map[string]any
+}
+
+func (e *fakeError) Error() string { return e.kind + ": " + e.message }
+
+func (f *fakeDynamoDB) serve(w http.ResponseWriter, r *http.Request) {
+	var request map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, operation, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+	f.mu.Lock()
+	before := f.before[operation]
+	delete(f.before, operation)
+	f.mu.Unlock()
+	if before != nil {
+		before()
+	}
+
+	f.mu.Lock()
+	response, err := f.handle(operation, request)
+	lost := err == nil && f.lost[operation] > 0
+	if lost {
+		f.lost[operation]--
+	}
+	f.mu.Unlock()
+
+	if lost {
+		<-r.Context().Done()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		body := map[string]any{"__type": "com.amazonaws.dynamodb.v20120810#" + err.kind, "message": err.message}
+		if err.reasons != nil {
+			body["CancellationReasons"] = err.reasons
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(body)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// writeRequest is a single item write of a PutItem, DeleteItem, batch or transaction call
+type writeRequest struct {
+	TableName                           string
+	Item                                attributes
+	Key                                 attributes
+	ConditionExpression                 string
+	UpdateExpression                    string
+	ExpressionAttributeNames            map[string]string
+	ExpressionAttributeValues           attributes
+	ReturnValuesOnConditionCheckFailure string
+}
+
+func (f *fakeDynamoDB) handle(operation string, request map[string]json.RawMessage) (any, *fakeError) {
+	switch operation {
+	case "DescribeTable":
+		var input struct{ TableName string }
+		decode(request, &input)
+		table, err := f.table(input.TableName)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"Table": table.describe(input.TableName)}, nil
+
+	case "GetItem":
+		var input writeRequest
+		decode(request, &input)
+		table, err := f.table(input.TableName)
+		if err != nil {
+			return nil, err
+		}
+		if item, ok := table.items[table.key(input.Key)]; ok {
+			return map[string]any{"Item": item}, nil
+		}
+		return map[string]any{}, nil
+
+	case "UpdateItem":
+		var input writeRequest
+		decode(request, &input)
+		return map[string]any{}, f.update(input)
+
+	case "PutItem", "DeleteItem":
+		var input writeRequest
+		decode(request, &input)
+		return map[string]any{}, f.write(input, operation == "DeleteItem")
+
+	case "BatchWriteItem":
+		var input struct {
+			RequestItems map[string][]struct {
+				PutRequest    *writeRequest
+				DeleteRequest *writeRequest
+			}
+		}
+		decode(request, &input)
+		for name, requests := range input.RequestItems {
+			for _, request := range requests {
+				write, deleted := request.PutRequest, false
+				if request.DeleteRequest != nil {
+					write, deleted = request.DeleteRequest, true
+				}
+				write.TableName = name
+				if err := f.write(*write, deleted); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return map[string]any{"UnprocessedItems": map[string]any{}}, nil
+
+	case "TransactWriteItems":
+		var input struct {
+			TransactItems []struct {
+				Put            *writeRequest
+				Delete         *writeRequest
+				Update         *writeRequest
+				ConditionCheck *writeRequest
+			}
+		}
+		decode(request, &input)
+		// a replayed token is a no-op when the transaction is the same, an error otherwise
+		var token struct{ ClientRequestToken string }
+		decode(request, &token)
+		var transaction any
+		_ = json.Unmarshal(request["TransactItems"], &transaction)
+		canonical, _ := json.Marshal(transaction)
+		if previous, ok := f.tokens[token.ClientRequestToken]; ok && token.ClientRequestToken != "" {
+			if previous != string(canonical) {
+				return nil, &fakeError{kind: "IdempotentParameterMismatchException", message: "the request uses the same client token as a previous, but non-identical request"}
+			}
+			return map[string]any{}, nil
+		}
+		reasons := make([]map[string]any, len(input.TransactItems))
+		failed := false
+		for i, action := range input.TransactItems {
+			reasons[i] = map[string]any{"Code": "None"}
+			for _, write := range []*writeRequest{action.Put, action.Delete, action.Update, action.ConditionCheck} {
+				if write != nil && f.conditionFails(*write) {
+					reasons[i] = map[string]any{"Code": "ConditionalCheckFailed"}
+					if existing := f.existing(*write); existing != nil && write.ReturnValuesOnConditionCheckFailure == "ALL_OLD" {
+						reasons[i]["Item"] = existing
+					}
+					failed = true
+				}
+			}
+		}
+		if failed {
+			return nil, &fakeError{kind: "TransactionCanceledException", message: "transaction cancelled", reasons: reasons}
+		}
+		if token.ClientRequestToken != "" {
+			f.tokens[token.ClientRequestToken] = string(canonical)
+		}
+		for _, action := range input.TransactItems {
+			switch {
+			case action.Put != nil:
+				_ = f.write(*action.Put, false)
+			case action.Delete != nil:
+				_ = f.write(*action.Delete, true)
+			case action.Update != nil:
+				_ = f.update(*action.Update)
+			}
+		}
+		return map[string]any{}, nil
+
+	case "GenerateDataKey":
+		var input struct{ KeyId string }
+		decode(request, &input)
+		dataKey := make([]byte, 32)
+		_, _ = rand.Read(dataKey)
+		return map[string]any{"KeyId": input.KeyId, "Plaintext": dataKey, "CiphertextBlob": dataKey}, nil
+
+	case "Decrypt":
+		var input struct {
+			KeyId          string
+			CiphertextBlob []byte
+		}
+		decode(request, &input)
+		return map[string]any{"KeyId": input.KeyId, "Plaintext": input.CiphertextBlob}, nil
+
+	case "Scan":
+		var input struct {
+			TableName         string
+			Segment           int
+			Limit             int
+			ExclusiveStartKey attributes
+		}
+		decode(request, &input)
+		table, err := f.table(input.TableName)
+		if err != nil {
+			return nil, err
+		}
+		// every item belongs to the first segment of a parallel scan
+		var items []attributes
+		var lastKey attributes
+		if input.Segment == 0 {
+			items, lastKey = table.page(table.sorted(nil, true), input.Limit, input.ExclusiveStartKey)
+		}
+		response := map[string]any{"Items": items, "Count": len(items), "ScannedCount": len(items)}
+		if lastKey != nil {
+			response["LastEvaluatedKey"] = lastKey
+		}
+		return response, nil
+
+	case "Query":
+		var input struct {
+			TableName                 string
+			KeyConditionExpression    string
+			ExpressionAttributeNames  map[string]string
+			ExpressionAttributeValues attributes
+			ScanIndexForward          *bool
+			Limit                     int
+			ExclusiveStartKey         attributes
+		}
+		decode(request, &input)
+		table, err := f.table(input.TableName)
+		if err != nil {
+			return nil, err
+		}
+		items, lastKey := table.query(input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues,
+			input.ScanIndexForward == nil || *input.ScanIndexForward, input.Limit, input.ExclusiveStartKey)
+		response := map[string]any{"Items": items, "Count": len(items), "ScannedCount": len(items)}
+		if lastKey != nil {
+			response["LastEvaluatedKey"] = lastKey
+		}
+		return response, nil
+	}
+	return nil, &fakeError{kind: "UnknownOperationException", message: "unsupported operation " + operation}
+}
+
+func decode(request map[string]json.RawMessage, input any) {
+	body, _ := json.Marshal(request)
+	_ = json.Unmarshal(body, input)
+}
+
+func (f *fakeDynamoDB) table(name string) (*fakeTable, *fakeError) {
+	table, ok := f.tables[name]
+	if !ok {
+		return nil, &fakeError{kind: "ResourceNotFoundException", message: "table not found: " + name}
+	}
+	return table, nil
+}
+
+// write puts or deletes an item when its condition holds
+func (f *fakeDynamoDB) write(request writeRequest, deleted bool) *fakeError {
+	table, err := f.table(request.TableName)
+	if err != nil {
+		return err
+	}
+	if f.conditionFails(request) {
+		return &fakeError{kind: "ConditionalCheckFailedException", message: "the conditional request failed"}
+	}
+	if deleted {
+		delete(table.items, table.key(request.Key))
+		return nil
+	}
+	table.items[table.key(request.Item)] = request.Item
+	return nil
+}
+
+// update applies the SET assignments of an update when its condition holds, creating the item
+// when it does not exist
+func (f *fakeDynamoDB) update(request writeRequest) *fakeError {
+	table, err := f.table(request.TableName)
+	if err != nil {
+		return err
+	}
+	if f.conditionFails(request) {
+		return &fakeError{kind: "ConditionalCheckFailedException", message: "the conditional request failed"}
+	}
+	item := make(attributes)
+	for name, value := range request.Key {
+		item[name] = value
+	}
+	for name, value := range f.existing(request) {
+		item[name] = value
+	}
+	for _, match := range assignment.FindAllStringSubmatch(strings.TrimPrefix(strings.TrimSpace(request.UpdateExpression), "SET "), -1) {
+		item[attributeName(match[1], request.ExpressionAttributeNames)] = request.ExpressionAttributeValues[match[2]]
+	}
+	table.items[table.key(item)] = item
+	return nil
+}
+
+var (
+	// assignment matches an assignment of a value to an attribute in a SET clause
+	assignment = regexp.MustCompile(`([#\w]+)\s*=\s*(:\w+)`)
+	// notExists matches a condition made of a single attribute_not_exists check
+	notExists = regexp.MustCompile(`^\s*attribute_not_exists\s*\(\s*([#\w]+)\s*\)\s*$`)
+	// comparison matches a condition made of a single comparison of an attribute with a value
+	comparison = regexp.MustCompile(`^\s*([#\w]+)\s*(<=|>=|<|>|=)\s*(:\w+)\s*$`)
+)
+
+// existing returns the item a write targets, nil when it does not exist
+func (f *fakeDynamoDB) existing(request writeRequest) attributes {
+	table, ok := f.tables[request.TableName]
+	if !ok {
+		return nil
+	}
+	key := request.Key
+	if key == nil {
+		key = request.Item
+	}
+	return table.items[table.key(key)]
+}
+
+// conditionFails reports whether the condition of a write rejects it. A disjunction of
+// conditions rejects it when all of them do.
+func (f *fakeDynamoDB) conditionFails(request writeRequest) bool {
+	existing := f.existing(request)
+	for _, condition := range strings.Split(request.ConditionExpression, " OR ") {
+		if !conditionFails(condition, existing, request) {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionFails reports whether a single condition rejects a write of the existing item
+func conditionFails(condition string, existing attributes, request writeRequest) bool {
+	if match := notExists.FindStringSubmatch(condition); match != nil {
+		_, ok := existing[attributeName(match[1], request.ExpressionAttributeNames)]
+		return ok
+	}
+	if match := comparison.FindStringSubmatch(condition); match != nil {
+		value, ok := existing[attributeName(match[1], request.ExpressionAttributeNames)]
+		return !ok || !compares(value, match[2], request.ExpressionAttributeValues[match[3]])
+	}
+	return false
+}
+
+func attributeName(name string, names map[string]string) string {
+	if resolved, ok := names[name]; ok {
+		return resolved
+	}
+	return name
+}
+
+// scalar returns the string form of a string or number attribute
+func scalar(value map[string]any) string {
+	for _, kind := range []string{"S", "N", "B"} {
+		if v, ok := value[kind].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// compareScalars orders two key attributes, numerically for numbers
+func compareScalars(a, b map[string]any) int {
+	if _, ok := a["N"]; ok {
+		x, _ := strconv.ParseFloat(scalar(a), 64)
+		y, _ := strconv.ParseFloat(scalar(b), 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(scalar(a), scalar(b))
+}
+
+func (t *fakeTable) key(item attributes) string {
+	key := scalar(item[t.partitionKey])
+	if t.sortKey != "" {
+		key += "\x00" + scalar(item[t.sortKey])
+	}
+	return key
+}
+
+func (t *fakeTable) describe(name string) map[string]any {
+	keySchema := []map[string]any{{"AttributeName": t.partitionKey, "KeyType": "HASH"}}
+	if t.sortKey != "" {
+		keySchema = append(keySchema, map[string]any{"AttributeName": t.sortKey, "KeyType": "RANGE"})
+	}
+	return map[string]any{
+		"TableName":          name,
+		"TableStatus":        "ACTIVE",
+		"KeySchema":          keySchema,
+		"ItemCount":          len(t.items),
+		"BillingModeSummary": map[string]any{"BillingMode": "PAY_PER_REQUEST"},
+	}
+}
+
+// partitionKeyCondition matches the partition key part of a key condition expression
+var partitionKeyCondition = regexp.MustCompile(`^\s*([#\w]+)\s*=\s*(:\w+)`)
+
+// sortKeyCondition matches the sort key part of a key condition expression
+var sortKeyCondition = regexp.MustCompile(`(?i)AND\s+(?:([#\w]+)\s*(<=|>=|<|>|=)\s*(:\w+)|begins_with\s*\(\s*([#\w]+)\s*,\s*(:\w+)\s*\))`)
+
+// query returns a page of the items matching a key condition on the partition key, and on
+// the sort key when given, in sort key order
+func (t *fakeTable) query(condition string, names map[string]string, values attributes, forward bool, limit int, startKey attributes) ([]attributes, attributes) {
+	partitionValue := ""
+	if match := partitionKeyCondition.FindStringSubmatch(condition); match != nil && attributeName(match[1], names) == t.partitionKey {
+		partitionValue = scalar(values[match[2]])
+	}
+	sortMatch := sortKeyCondition.FindStringSubmatch(condition)
+
+	return t.page(t.sorted(func(item attributes) bool {
+		return scalar(item[t.partitionKey]) == partitionValue &&
+			(sortMatch == nil || matchesSortKey(item[t.sortKey], sortMatch, values))
+	}, forward), limit, startKey)
+}
+
+// sorted returns the items accepted by the filter, all of them when nil, in key order
+func (t *fakeTable) sorted(filter func(item attributes) bool, forward bool) []attributes {
+	var items []attributes
+	for _, item := range t.items {
+		if filter == nil || filter(item) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		order := compareScalars(items[i][t.sortKey], items[j][t.sortKey])
+		if order == 0 {
+			order = strings.Compare(scalar(items[i][t.partitionKey]), scalar(items[j][t.partitionKey]))
+		}
+		if forward {
+			return order < 0
+		}
+		return order > 0
+	})
+	return items
+}
+
+// page returns the items following the start key, up to the limit, and the key to continue
+// from when items are left
+func (t *fakeTable) page(items []attributes, limit int, startKey attributes) ([]attributes, attributes) {
+	if startKey != nil {
+		for i, item := range items {
+			if t.key(item) == t.key(startKey) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+		last := items[len(items)-1]
+		lastKey := attributes{t.partitionKey: last[t.partitionKey]}
+		if t.sortKey != "" {
+			lastKey[t.sortKey] = last[t.sortKey]
+		}
+		return items, lastKey
+	}
+	return items, nil
+}
+
+func matchesSortKey(value map[string]any, match []string, values attributes) bool {
+	if match[4] != "" {
+		return strings.HasPrefix(scalar(value), scalar(values[match[5]]))
+	}
+	return compares(value, match[2], values[match[3]])
+}
+
+// compares reports whether the value compares to the operand with the given operator
+func compares(value map[string]any, operator string, operand map[string]any) bool {
+	order := compareScalars(value, operand)
+	switch operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return order == 0
+}
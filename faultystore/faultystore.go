@@ -0,0 +1,120 @@
+// Package faultystore provides a persistence.StateStore decorator injecting DynamoDB-like
+// failures, to test how actor supervision and retries behave when the durable store misbehaves.
+package faultystore
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+	"github.com/tochemey/ego/v3/persistence"
+)
+
+// FaultConfig defines the faults injected into WriteState and GetLatestState.
+// Rates are probabilities between 0 and 1 evaluated independently on every call.
+type FaultConfig struct {
+	// Latency is added to every call
+	Latency time.Duration
+	// LatencyJitter adds a random extra latency between zero and its value
+	LatencyJitter time.Duration
+	// ThrottleRate is the rate of calls failing with a ProvisionedThroughputExceededException
+	// without reaching the underlying store
+	ThrottleRate float64
+	// ErrorRate is the rate of calls failing with an InternalServerError without reaching
+	// the underlying store
+	ErrorRate float64
+	// PartialFailureRate is the rate of writes applied by the underlying store but reported
+	// as failed, as happens when a response is lost after a timeout
+	PartialFailureRate float64
+}
+
+// faultyStore decorates a StateStore with injected faults
+type faultyStore struct {
+	store  persistence.StateStore
+	config FaultConfig
+}
+
+// enforce interface implementation
+var _ persistence.StateStore = (*faultyStore)(nil)
+
+// Wrap returns a StateStore injecting the configured faults into the calls made to store
+func Wrap(store persistence.StateStore, config FaultConfig) persistence.StateStore {
+	return &faultyStore{
+		store:  store,
+		config: config,
+	}
+}
+
+// Connect connects the underlying store
+func (f *faultyStore) Connect(ctx context.Context) error {
+	return f.store.Connect(ctx)
+}
+
+// Disconnect disconnects the underlying store
+func (f *faultyStore) Disconnect(ctx context.Context) error {
+	return f.store.Disconnect(ctx)
+}
+
+// Ping pings the underlying store
+func (f *faultyStore) Ping(ctx context.Context) error {
+	return f.store.Ping(ctx)
+}
+
+// WriteState persists the state in the underlying store unless a fault is injected
+func (f *faultyStore) WriteState(ctx context.Context, state *egopb.DurableState) error {
+	if err := f.inject(ctx); err != nil {
+		return fmt.Errorf("failed to upsert state into the dynamodb: %w", err)
+	}
+
+	if err := f.store.WriteState(ctx, state); err != nil {
+		return err
+	}
+
+	if hit(f.config.PartialFailureRate) {
+		return fmt.Errorf("failed to upsert state into the dynamodb: %w", context.DeadlineExceeded)
+	}
+	return nil
+}
+
+// GetLatestState fetches the state from the underlying store unless a fault is injected
+func (f *faultyStore) GetLatestState(ctx context.Context, persistenceID string) (*egopb.DurableState, error) {
+	if err := f.inject(ctx); err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest state from the dynamodb: %w", err)
+	}
+	return f.store.GetLatestState(ctx, persistenceID)
+}
+
+// inject waits for the configured latency and returns the injected error, if any
+func (f *faultyStore) inject(ctx context.Context) error {
+	latency := f.config.Latency
+	if f.config.LatencyJitter > 0 {
+		latency += rand.N(f.config.LatencyJitter)
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case hit(f.config.ThrottleRate):
+		return &types.ProvisionedThroughputExceededException{Message: aws.String("injected throttling")}
+	case hit(f.config.ErrorRate):
+		return &types.InternalServerError{Message: aws.String("injected failure")}
+	default:
+		return nil
+	}
+}
+
+// hit draws whether an event of the given rate happens
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
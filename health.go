@@ -0,0 +1,74 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultHealthCacheTTL is how long the result of a table health check is reused by default
+const DefaultHealthCacheTTL = 5 * time.Second
+
+// TableHealth describes the states table as last seen by the store
+type TableHealth struct {
+	TableName string
+	// Status is the DynamoDB table status, the table is usable when it is ACTIVE
+	Status types.TableStatus
+	// ItemCount and SizeBytes are approximations refreshed by DynamoDB every six hours
+	ItemCount int64
+	SizeBytes int64
+	CheckedAt time.Time
+}
+
+// healthCache keeps the last table health check result for a short time so that frequent
+// health probes do not translate into as many DescribeTable calls
+type healthCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	health *TableHealth
+	err    error
+}
+
+// Health describes the states table. The result is cached for the configured health cache TTL.
+func (d *DynamoDurableStore) Health(ctx context.Context) (*TableHealth, error) {
+	d.health.mu.Lock()
+	defer d.health.mu.Unlock()
+
+	if d.health.health != nil && time.Since(d.health.health.CheckedAt) < d.health.ttl {
+		health := *d.health.health
+		return &health, d.health.err
+	}
+
+	var resp *dynamodb.DescribeTableOutput
+	err := d.execute(ctx, OperationPing, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(d.schema.TableName),
+		})
+		return err
+	})
+
+	health := &TableHealth{
+		TableName: d.schema.TableName,
+		CheckedAt: time.Now(),
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failed to describe the table in the dynamodb: %w", err)
+	default:
+		health.Status = resp.Table.TableStatus
+		health.ItemCount = aws.ToInt64(resp.Table.ItemCount)
+		health.SizeBytes = aws.ToInt64(resp.Table.TableSizeBytes)
+		if health.Status != types.TableStatusActive {
+			err = fmt.Errorf("table=%s is not active: status=%s", d.schema.TableName, health.Status)
+		}
+	}
+
+	d.health.health, d.health.err = health, err
+	result := *health
+	return &result, err
+}
@@ -0,0 +1,103 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// DefaultHedgeBudget is the share of the reads that may be hedged when no budget is configured
+	DefaultHedgeBudget = 0.05
+	// maxHedgeBurst is the number of hedges the budget can save up during a quiet period
+	maxHedgeBurst = 10
+)
+
+// HedgePolicy configures the hedged reads of GetLatestState
+type HedgePolicy struct {
+	// Delay is how long a read waits for its response before a second, hedged, read of the same
+	// item is issued. It is typically set to the p95 latency of GetLatestState.
+	Delay time.Duration
+	// Budget is the share of the reads that may be hedged, between 0 and 1, so that a slow table
+	// does not see its read traffic doubled. It defaults to DefaultHedgeBudget.
+	Budget float64
+}
+
+// hedging issues the hedged reads within their budget. Every read earns Budget of a hedge and
+// a hedge spends a whole one.
+type hedging struct {
+	policy HedgePolicy
+	mu     sync.Mutex
+	tokens float64
+}
+
+// newHedging creates the hedging of the given policy
+func newHedging(policy HedgePolicy) *hedging {
+	if policy.Budget <= 0 {
+		policy.Budget = DefaultHedgeBudget
+	}
+	return &hedging{policy: policy}
+}
+
+// earn credits the budget for a read
+func (h *hedging) earn() {
+	h.mu.Lock()
+	h.tokens = min(h.tokens+h.policy.Budget, maxHedgeBurst)
+	h.mu.Unlock()
+}
+
+// spend reports whether the budget allows a hedge and debits it
+func (h *hedging) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+// hedgedGetItem reads an item and, when the hedged reads are enabled and the response takes longer
+// than the hedge delay, issues a second read and returns the first successful response. The slower
+// read is cancelled.
+func (d *DynamoDurableStore) hedgedGetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if d.hedging == nil {
+		return d.getItem(ctx, input)
+	}
+	d.hedging.earn()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *dynamodb.GetItemOutput
+		err  error
+	}
+	// buffered so that the slower read does not block once the faster one has been returned
+	results := make(chan result, 2)
+	get := func() {
+		resp, err := d.getItem(ctx, input)
+		results <- result{resp: resp, err: err}
+	}
+
+	go get()
+	pending := 1
+	timer := time.NewTimer(d.hedging.policy.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil || pending == 0 {
+				return result.resp, result.err
+			}
+		case <-timer.C:
+			if d.hedging.spend() {
+				pending++
+				go get()
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// HistoryTimestampIndex is the local secondary index of the history table keyed by Timestamp
+const HistoryTimestampIndex = "TimestampIndex"
+
+// HistoryRetention bounds the number of versions kept per persistence ID in the history table
+type HistoryRetention struct {
+	// KeepLast is the number of most recent versions kept per persistence ID, zero keeps all of them
+	KeepLast int
+	// TrimOnWrite deletes the versions falling out of the retention right after every write.
+	// Otherwise they are deleted by TrimHistory, see RunHistorySweeper.
+	TrimOnWrite bool
+}
+
+// history records every version of the states in a table keyed by PersistenceID and VersionNumber
+type history struct {
+	table     string
+	retention HistoryRetention
+}
+
+// historyPut returns the action recording the given item in the history table
+func (d *DynamoDurableStore) historyPut(item *StateItem) *types.Put {
+	return &types.Put{
+		TableName: aws.String(d.history.table),
+		Item:      d.schema.marshal(item),
+	}
+}
+
+// trimAfterWrite deletes the versions of the written item falling out of the retention,
+// when trimming on write is enabled. The write already succeeded, so failures are only logged.
+func (d *DynamoDurableStore) trimAfterWrite(ctx context.Context, item *StateItem) {
+	if d.history == nil || !d.history.retention.TrimOnWrite {
+		return
+	}
+	if _, err := d.trimVersions(ctx, item.PersistenceID, item.VersionNumber); err != nil {
+		d.logger.Warn("failed to trim the state history", "persistenceID", item.PersistenceID, "error", err)
+	}
+}
+
+// trimVersions deletes the versions of the given partition key falling out of the retention,
+// latest being the most recent version, and returns how many were deleted
+func (d *DynamoDurableStore) trimVersions(ctx context.Context, partitionKey string, latest uint64) (int, error) {
+	keepLast := uint64(d.history.retention.KeepLast)
+	if keepLast == 0 || latest <= keepLast {
+		return 0, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(d.history.table),
+		KeyConditionExpression:   aws.String("#PersistenceID = :id AND #VersionNumber <= :cutoff"),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":     &types.AttributeValueMemberS{Value: partitionKey},
+			":cutoff": &types.AttributeValueMemberN{Value: strconv.FormatUint(latest-keepLast, 10)},
+		},
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+
+	trimmed := 0
+	for {
+		var resp *dynamodb.QueryOutput
+		err := d.execute(ctx, OperationTrimHistory, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Query(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationTrimHistory, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to query the history of persistenceID=%s: %w", partitionKey, err)
+		}
+
+		for start := 0; start < len(resp.Items); start += maxBatchWriteItems {
+			end := min(start+maxBatchWriteItems, len(resp.Items))
+			requests := make([]types.WriteRequest, 0, end-start)
+			for _, key := range resp.Items[start:end] {
+				requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+			}
+			if err := d.batchWriteRequests(ctx, OperationTrimHistory, d.history.table, requests); err != nil {
+				return trimmed, fmt.Errorf("failed to trim the history of persistenceID=%s: %w", partitionKey, err)
+			}
+			trimmed += len(requests)
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return trimmed, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// TrimHistory deletes, for every state, the versions of the history table falling out of the
+// retention and returns how many were deleted
+func (d *DynamoDurableStore) TrimHistory(ctx context.Context) (int, error) {
+	if d.history == nil {
+		return 0, errors.New("history is not enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber"),
+		ReturnConsumedCapacity:   d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, prefix)
+
+	trimmed := 0
+	latest := newLatestVersions()
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationTrimHistory, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationTrimHistory, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to scan the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			count, err := d.trimStateHistory(ctx, latest, attributes)
+			trimmed += count
+			if err != nil {
+				return trimmed, err
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return trimmed, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// trimStateHistory trims the history of the scanned state with the given attributes. The history
+// of a hot persistence ID is keyed by its unsharded partition key and trimmed once, for the item
+// holding its newest version.
+func (d *DynamoDurableStore) trimStateHistory(ctx context.Context, latest *latestVersions, attributes map[string]types.AttributeValue) (int, error) {
+	partitionKey := parseDynamoString(attributes[d.schema.PartitionKey])
+	version, err := parseDynamoUint64(attributes[d.schema.attr("VersionNumber")])
+	if err != nil {
+		return 0, err
+	}
+	latestCopy, err := d.isLatestCopy(ctx, latest, partitionKey, version)
+	if err != nil || !latestCopy {
+		return 0, err
+	}
+	return d.trimVersions(ctx, d.basePartitionKey(partitionKey), version)
+}
+
+// RunHistorySweeper calls TrimHistory every interval until the context is done
+func (d *DynamoDurableStore) RunHistorySweeper(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.TrimHistory(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetStateAt returns the newest version of the durable state written at or before the given
+// timestamp, expressed in the unit of DurableState.Timestamp. It returns nil when the state had
+// no version yet at that time or when the version has been trimmed by the history retention.
+func (d *DynamoDurableStore) GetStateAt(ctx context.Context, persistenceID string, timestamp int64) (*egopb.DurableState, error) {
+	if d.history == nil {
+		return nil, errors.New("history is not enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dynamodb.QueryOutput
+	err = d.execute(ctx, OperationGetStateAt, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Query(ctx, &dynamodb.QueryInput{
+			TableName:                aws.String(d.history.table),
+			IndexName:                aws.String(HistoryTimestampIndex),
+			KeyConditionExpression:   aws.String("#PersistenceID = :id AND #Timestamp <= :timestamp"),
+			ExpressionAttributeNames: d.schema.names("PersistenceID", "Timestamp"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id":        &types.AttributeValueMemberS{Value: prefix + persistenceID},
+				":timestamp": &types.AttributeValueMemberN{Value: strconv.FormatInt(timestamp, 10)},
+			},
+			ScanIndexForward:       aws.Bool(false),
+			Limit:                  aws.Int32(1),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationGetStateAt, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the history of persistenceID=%s: %w", persistenceID, err)
+	}
+
+	if len(resp.Items) == 0 {
+		return nil, nil
+	}
+	item, err := d.schema.unmarshal(resp.Items[0])
+	if err != nil {
+		return nil, err
+	}
+	return d.toDurableState(ctx, item, prefix, OperationGetStateAt)
+}
+
+// createHistoryTable creates the history table keyed by PersistenceID and VersionNumber, with
+// a local secondary index keyed by PersistenceID and Timestamp
+func (d *DynamoDurableStore) createHistoryTable(ctx context.Context) error {
+	_, err := d.client.Load().CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(d.history.table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(d.schema.PartitionKey), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(d.schema.attr("VersionNumber")), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String(d.schema.attr("Timestamp")), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(d.schema.PartitionKey), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(d.schema.attr("VersionNumber")), KeyType: types.KeyTypeRange},
+		},
+		LocalSecondaryIndexes: []types.LocalSecondaryIndex{
+			{
+				IndexName: aws.String(HistoryTimestampIndex),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String(d.schema.PartitionKey), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String(d.schema.attr("Timestamp")), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create the history table: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(d.client.Load())
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.history.table)}, tableActiveTimeout); err != nil {
+		return fmt.Errorf("failed to wait for the history table to be active: %w", err)
+	}
+	return nil
+}
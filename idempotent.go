@@ -0,0 +1,129 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxClientRequestTokenLength is the maximum length of a DynamoDB transaction client request token
+const maxClientRequestTokenLength = 36
+
+// clientRequestToken derives the transaction token of a state version. DynamoDB ignores a
+// transaction replaying a token seen in the last ten minutes, which makes retried writes idempotent.
+func clientRequestToken(partitionKey string, version uint64) string {
+	sum := sha256.Sum256([]byte(partitionKey + "/" + strconv.FormatUint(version, 10)))
+	return hex.EncodeToString(sum[:])[:maxClientRequestTokenLength]
+}
+
+// writeStateTransaction persists the item with a transaction that records the version in the
+// history table when history is enabled, appends an audit entry when the audit log is enabled,
+// and carries the client request token of the version in idempotent mode. The audit entry of an
+// idempotent transaction is appended once it succeeded instead, since its time would make a
+// retried transaction differ from the first one.
+func (d *DynamoDurableStore) writeStateTransaction(ctx context.Context, item *StateItem) error {
+	if !d.idempotentWrites {
+		return d.appendAudited(ctx, item.PersistenceID, item.VersionNumber, OperationWriteState, func(audit *auditAppend) error {
+			return d.stateTransaction(ctx, item, audit)
+		})
+	}
+
+	if err := d.stateTransaction(ctx, item, nil); err != nil {
+		return err
+	}
+	return d.appendAudited(ctx, item.PersistenceID, item.VersionNumber, OperationWriteState, func(audit *auditAppend) error {
+		return d.putAudit(ctx, audit, stateKey(item), item.VersionNumber, OperationWriteState)
+	})
+}
+
+// stateTransaction runs the transaction of writeStateTransaction, appending the given audit entry
+// and anchoring it in the item when not nil
+func (d *DynamoDurableStore) stateTransaction(ctx context.Context, item *StateItem, audit *auditAppend) error {
+	if audit != nil {
+		item.AuditSignature = audit.signature
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName: aws.String(d.schema.TableName),
+					Item:      d.stateAttributes(item),
+				},
+			},
+		},
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if d.history != nil {
+		input.TransactItems = append(input.TransactItems, types.TransactWriteItem{Put: d.historyPut(item)})
+	}
+	if audit != nil {
+		input.TransactItems = append(input.TransactItems, audit.actions()...)
+	}
+	if d.idempotentWrites {
+		input.ClientRequestToken = aws.String(clientRequestToken(item.PersistenceID, item.VersionNumber))
+	}
+
+	err := d.execute(ctx, OperationWriteState, func(ctx context.Context) error {
+		resp, err := d.client.Load().TransactWriteItems(ctx, input)
+		if err == nil {
+			for index := range resp.ConsumedCapacity {
+				d.observeConsumedCapacity(ctx, OperationWriteState, &resp.ConsumedCapacity[index])
+			}
+		}
+		return err
+	})
+	return d.idempotentMismatch(ctx, item, err)
+}
+
+// idempotentMismatch handles the IdempotentParameterMismatchException of an idempotent
+// transaction and returns any other error as is. DynamoDB raises it both when a different state
+// is written under an already written version and when a write whose first attempt succeeded
+// is retried, since the rebuilt item carries a new UpdatedAt. The latter is told apart by
+// reading the stored item back and is not an error.
+func (d *DynamoDurableStore) idempotentMismatch(ctx context.Context, item *StateItem, err error) error {
+	var mismatch *types.IdempotentParameterMismatchException
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+
+	written, readErr := d.isWritten(ctx, item)
+	if readErr != nil {
+		return errors.Join(err, readErr)
+	}
+	if written {
+		return nil
+	}
+	return fmt.Errorf("version=%d of persistenceID=%s has already been written with a different state: %w",
+		item.VersionNumber, item.PersistenceID, err)
+}
+
+// isWritten reports whether the states table holds the version and the state of the item
+// under the key it is written to. The payloads are compared once decoded, since encoding the
+// same state twice does not always give the same item.
+func (d *DynamoDurableStore) isWritten(ctx context.Context, item *StateItem) (bool, error) {
+	stored, err := d.getStateItem(WithConsistentReadContext(ctx), stateKey(item))
+	if err != nil {
+		return false, err
+	}
+	if stored == nil || stored.DeletedAt > 0 || stored.VersionNumber != item.VersionNumber || stored.StateManifest != item.StateManifest {
+		return false, nil
+	}
+
+	storedPayload, err := d.decodePayload(ctx, stored)
+	if err != nil {
+		return false, err
+	}
+	payload, err := d.decodePayload(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(storedPayload, payload), nil
+}
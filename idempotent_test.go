@@ -0,0 +1,103 @@
+package dynamodb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// writeAfterLostResponse writes the state with the response of its transaction lost, as after
+// a network timeout, and makes sure the write failed
+func writeAfterLostResponse(t *testing.T, fake *fakeDynamoDB, write func(ctx context.Context) error) {
+	t.Helper()
+	fake.loseResponse("TransactWriteItems")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := write(ctx); err == nil {
+		t.Fatal("write with a lost response succeeded, want a timeout")
+	}
+}
+
+func TestIdempotentWriteRetriedAfterLostResponse(t *testing.T) {
+	writeState := func(store *DynamoDurableStore) func(ctx context.Context, state *egopb.DurableState) error {
+		return store.WriteState
+	}
+	tests := []struct {
+		name    string
+		options []Option
+		// attribute is written by the option under test
+		attribute string
+		write     func(store *DynamoDurableStore) func(ctx context.Context, state *egopb.DurableState) error
+	}{
+		{name: "state", write: writeState},
+		{
+			name:    "history",
+			options: []Option{WithHistory("history", HistoryRetention{})},
+			write:   writeState,
+		},
+		{
+			name:      "kms encryption",
+			options:   []Option{WithKMSKeyARN("arn:aws:kms:us-east-1:111122223333:key/orders")},
+			attribute: "EncryptedDataKey",
+			write:     writeState,
+		},
+		{
+			name:      "compression",
+			options:   []Option{WithCompression(GzipCompression, 1)},
+			attribute: "PayloadCodec",
+			write:     writeState,
+		},
+		{
+			name:    "outbox",
+			options: []Option{WithOutboxTable("outbox")},
+			write: func(store *DynamoDurableStore) func(ctx context.Context, state *egopb.DurableState) error {
+				return func(ctx context.Context, state *egopb.DurableState) error {
+					return store.WriteStateWithOutbox(ctx, state, []proto.Message{wrapperspb.String("order placed")})
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, fake := newFakeStore(t, append(test.options, WithIdempotentWrites(), WithoutRetry())...)
+			fake.createTable("history", "PersistenceID", "VersionNumber")
+			fake.createTable("outbox", "MessageID", "")
+			write := test.write(store)
+			state := testState(t, "order-1", 1)
+
+			writeAfterLostResponse(t, fake, func(ctx context.Context) error { return write(ctx, state) })
+			if err := write(context.Background(), state); err != nil {
+				t.Fatalf("retried write = %v", err)
+			}
+			if _, ok := fake.items(DefaultTableName)[0][test.attribute]; test.attribute != "" && !ok {
+				t.Fatalf("state item has no %s attribute", test.attribute)
+			}
+
+			got, err := store.GetLatestState(WithConsistentReadContext(context.Background()), "order-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDurableState(t, got, state)
+		})
+	}
+}
+
+func TestIdempotentWriteStateRejectsDifferentState(t *testing.T) {
+	store, fake := newFakeStore(t, WithIdempotentWrites(), WithoutRetry())
+
+	writeAfterLostResponse(t, fake, func(ctx context.Context) error {
+		return store.WriteState(ctx, testState(t, "order-1", 1))
+	})
+	different := testState(t, "order-2", 1)
+	different.PersistenceId = "order-1"
+	err := store.WriteState(context.Background(), different)
+	if err == nil || !strings.Contains(err.Error(), "different state") {
+		t.Fatalf("WriteState of a different state = %v, want a different state error", err)
+	}
+}
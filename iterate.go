@@ -0,0 +1,106 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// IterateAllStates calls fn for the latest state of every persistence ID in the table.
+// The table is read with a parallel scan split into the given number of segments, each
+// segment being consumed by its own goroutine: fn must therefore be safe for concurrent use.
+// A segment only fetches its next page once fn has processed the current one, which bounds
+// memory usage and lets a slow fn throttle the scan. The first error returned by fn or by
+// DynamoDB stops the iteration and is returned.
+func (d *DynamoDurableStore) IterateAllStates(ctx context.Context, segments int, fn func(*egopb.DurableState) error) error {
+	if segments < 1 {
+		segments = 1
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		latest   = newLatestVersions()
+	)
+	for segment := 0; segment < segments; segment++ {
+		wg.Add(1)
+		go func(segment int32) {
+			defer wg.Done()
+			if err := d.iterateSegment(ctx, prefix, latest, segment, int32(segments), fn); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(int32(segment))
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// iterateSegment scans the given segment page by page and calls fn for every state
+func (d *DynamoDurableStore) iterateSegment(ctx context.Context, prefix string, latest *latestVersions, segment, totalSegments int32, fn func(*egopb.DurableState) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(d.schema.TableName),
+		Segment:                aws.Int32(segment),
+		TotalSegments:          aws.Int32(totalSegments),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, prefix)
+
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationIterateAllStates, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationIterateAllStates, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan segment=%d of the dynamodb: %w", segment, err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return err
+			}
+			if item.DeletedAt > 0 {
+				continue
+			}
+			latestCopy, err := d.isLatestCopy(ctx, latest, item.PersistenceID, item.VersionNumber)
+			if err != nil {
+				return err
+			}
+			if !latestCopy {
+				continue
+			}
+			state, err := d.toDurableState(ctx, item, prefix, OperationIterateAllStates)
+			if err != nil {
+				return err
+			}
+			if err := fn(state); err != nil {
+				return err
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,121 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Connect creates the AWS clients of the store, resolving the AWS configuration from the
+// environment unless WithAWSConfig is set, and starts the background flushes of the
+// write-behind mode. It is safe for concurrent use and a no-op when the store is connected.
+// Calling it is optional: the first call needing the clients connects the store. With
+// WithWarmUp, Connect also resolves the credentials and opens the HTTP connections upfront.
+func (d *DynamoDurableStore) Connect(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.connected.Load() {
+		return nil
+	}
+
+	cfg := d.awsConfig
+	if cfg == nil {
+		defaultConfig, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load the AWS configuration: %w", err)
+		}
+		cfg = &defaultConfig
+	}
+	warmUpConfig := d.warmUpConfig.configure(*cfg)
+	d.newClients(warmUpConfig)
+	if err := d.warmUp(ctx, warmUpConfig); err != nil {
+		return err
+	}
+
+	if d.writeBehind != nil {
+		d.writeBehind.start()
+		go d.runWriteBehind()
+	}
+	d.connected.Store(true)
+	return nil
+}
+
+// Disconnect stops the background flushes of the write-behind mode and flushes the buffered
+// states. The clients are stateless and need no closing. A later call reconnects the store.
+func (d *DynamoDurableStore) Disconnect(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.connected.Load() {
+		return nil
+	}
+
+	var err error
+	if d.writeBehind != nil {
+		err = d.closeWriteBehind(ctx)
+	}
+	d.connected.Store(false)
+	return err
+}
+
+// Connected reports whether the store is connected
+func (d *DynamoDurableStore) Connected() bool {
+	return d.connected.Load()
+}
+
+// ensureConnected connects the store on the first call needing the AWS clients
+func (d *DynamoDurableStore) ensureConnected(ctx context.Context) error {
+	if d.connected.Load() {
+		return nil
+	}
+	return d.Connect(ctx)
+}
+
+// newClients creates the AWS clients of the store from the given configuration. The clients
+// are stored atomically: calls started before a reconnection keep using the previous ones.
+func (d *DynamoDurableStore) newClients(cfg aws.Config) {
+	if d.regions != nil {
+		cfg = cfg.Copy()
+		cfg.Region = d.regions.primary
+	}
+
+	client := dynamodb.NewFromConfig(cfg, func(options *dynamodb.Options) {
+		if d.endpoint != "" {
+			options.BaseEndpoint = aws.String(d.endpoint)
+		}
+		options.APIOptions = append(options.APIOptions, d.apiOptions...)
+	})
+	d.client.Store(client)
+	if d.regions != nil {
+		clients := map[string]*dynamodb.Client{d.regions.primary: client}
+		for _, region := range d.regions.replicas {
+			clients[region] = dynamodb.NewFromConfig(cfg, func(options *dynamodb.Options) {
+				options.Region = region
+				options.APIOptions = append(options.APIOptions, d.apiOptions...)
+			})
+		}
+		d.regions.clients.Store(&clients)
+	}
+	d.streams.Store(dynamodbstreams.NewFromConfig(cfg, func(options *dynamodbstreams.Options) {
+		if d.endpoint != "" {
+			options.BaseEndpoint = aws.String(d.endpoint)
+		}
+	}))
+
+	d.s3.Store(s3.NewFromConfig(cfg))
+	if d.overflow != nil {
+		d.overflow.client.Store(d.s3.Load())
+	}
+	if d.encryption != nil {
+		d.encryption.client.Store(kms.NewFromConfig(cfg))
+	}
+	if d.autoScaling != nil {
+		d.autoScaling.client.Store(applicationautoscaling.NewFromConfig(cfg))
+	}
+}
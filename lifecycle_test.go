@@ -0,0 +1,52 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestReconnectWhileReading(t *testing.T) {
+	store, _ := newFakeStore(t)
+	ctx := context.Background()
+	if err := store.WriteState(ctx, testState(t, "order-1", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := store.GetLatestState(ctx, "order-1"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for range 3000 {
+		if err := store.Disconnect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Connect(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	state, err := store.GetLatestState(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDurableState(t, state, testState(t, "order-1", 1))
+}
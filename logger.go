@@ -0,0 +1,50 @@
+package dynamodb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultSlowOperationThreshold is the duration above which a DynamoDB call is logged as slow
+const DefaultSlowOperationThreshold = time.Second
+
+// Logger receives the structured log entries of the store. keysAndValues alternate keys
+// and values. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, keysAndValues ...any)
+	Warn(msg string, keysAndValues ...any)
+}
+
+// noopLogger is the default Logger and discards every entry
+type noopLogger struct{}
+
+// enforce interface implementation
+var _ Logger = noopLogger{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Warn(string, ...any)  {}
+
+// logAttempt logs the outcome of a single DynamoDB call worth reporting
+func (d *DynamoDurableStore) logAttempt(operation string, duration time.Duration, err error) {
+	if d.slowThreshold > 0 && duration >= d.slowThreshold {
+		d.logger.Warn("slow dynamodb call", "operation", operation, "duration", duration, "error", err)
+	}
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		d.logger.Debug("dynamodb conditional check failed", "operation", operation)
+		return
+	}
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		reasons := make([]string, 0, len(canceled.CancellationReasons))
+		for _, reason := range canceled.CancellationReasons {
+			if reason.Code != nil {
+				reasons = append(reasons, *reason.Code)
+			}
+		}
+		d.logger.Debug("dynamodb transaction canceled", "operation", operation, "reasons", reasons)
+	}
+}
@@ -0,0 +1,68 @@
+package dynamodb
+
+import "time"
+
+// Operation names reported to the Metrics hooks
+const (
+	OperationPing               = "Ping"
+	OperationWriteState         = "WriteState"
+	OperationGetLatestState     = "GetLatestState"
+	OperationWriteOutbox        = "WriteStateWithOutbox"
+	OperationPollOutbox         = "PendingOutboxMessages"
+	OperationMarkDelivered      = "MarkOutboxDelivered"
+	OperationGetStatesByShard   = "GetStatesByShard"
+	OperationListPersistenceIDs = "ListPersistenceIDs"
+	OperationIterateAllStates   = "IterateAllStates"
+	OperationFlush              = "Flush"
+	OperationDeleteState        = "DeleteState"
+	OperationRestoreState       = "Restore"
+	OperationReapTombstones     = "ReapTombstones"
+	OperationWriteStates        = "WriteStates"
+	OperationExport             = "ExportToS3"
+	OperationImport             = "ImportFromS3"
+	OperationTrimHistory        = "TrimHistory"
+	OperationGetStateAt         = "GetStateAt"
+	OperationRebuildProjection  = "RebuildProjection"
+	OperationSaveCheckpoint     = "SaveCheckpoint"
+	OperationQueryStates        = "QueryStates"
+	OperationStats              = "Stats"
+	OperationFindPersistenceIDs = "FindPersistenceIDs"
+	OperationCompact            = "Compact"
+	OperationClaimOutbox        = "ClaimOutboxMessage"
+	OperationGetUpdatedSince    = "GetStatesUpdatedSince"
+	OperationAuditTrail         = "AuditTrail"
+	OperationSaveSnapshot       = "SaveSnapshot"
+	OperationLatestSnapshot     = "LatestSnapshot"
+	OperationPruneSnapshots     = "PruneSnapshots"
+)
+
+// Metrics receives measurements about the calls the store makes to DynamoDB.
+// Implementations must be safe for concurrent use. They can forward the values to
+// Prometheus, OpenTelemetry or any other metrics backend.
+type Metrics interface {
+	// ObserveLatency records how long a single DynamoDB call of the given operation took
+	ObserveLatency(operation string, duration time.Duration)
+	// IncRequests counts a DynamoDB call, retries included, for the given operation and whether it failed
+	IncRequests(operation string, failed bool)
+	// ObserveItemSize records the size in bytes of the state payload read or written
+	ObserveItemSize(operation string, bytes int)
+	// ObserveConsumedCapacity records the read or write capacity units consumed by the operation
+	ObserveConsumedCapacity(operation string, capacityUnits float64)
+	// IncThrottled counts a request rejected by DynamoDB because the throughput was exceeded
+	IncThrottled(operation string)
+	// ObserveRateLimitWait records how long a call waited for the read or write rate limiter
+	ObserveRateLimitWait(operation string, wait time.Duration)
+}
+
+// noopMetrics is the default Metrics implementation and discards every measurement
+type noopMetrics struct{}
+
+// enforce interface implementation
+var _ Metrics = noopMetrics{}
+
+func (noopMetrics) ObserveLatency(string, time.Duration)       {}
+func (noopMetrics) IncRequests(string, bool)                   {}
+func (noopMetrics) ObserveItemSize(string, int)                {}
+func (noopMetrics) ObserveConsumedCapacity(string, float64)    {}
+func (noopMetrics) IncThrottled(string)                        {}
+func (noopMetrics) ObserveRateLimitWait(string, time.Duration) {}
@@ -0,0 +1,158 @@
+// Package migration copies durable states from another ego state store, such as the Postgres
+// durable store, into the DynamoDB durable store, and verifies the copy.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"github.com/tochemey/ego/v3/persistence"
+)
+
+// DefaultPageSize is the number of persistence IDs migrated per page when none is configured
+const DefaultPageSize = 100
+
+// PersistenceIDSource enumerates the persistence IDs of the source store page by page.
+// An empty pageToken requests the first page, an empty nextPageToken marks the last page.
+// The persistence.StateStore interface has no listing method, so the enumeration is provided
+// separately, for instance with a SELECT DISTINCT query on the source table.
+type PersistenceIDSource interface {
+	PersistenceIDs(ctx context.Context, pageSize uint64, pageToken string) (persistenceIDs []string, nextPageToken string, err error)
+}
+
+// BatchWriter persists the states newer than the stored ones and returns how many it wrote.
+// It must never replace a state by an older version. The DynamoDB durable store implements it.
+type BatchWriter interface {
+	WriteStatesIfNewer(ctx context.Context, states []*egopb.DurableState) (int, error)
+}
+
+// Config configures a migration
+type Config struct {
+	// PageSize is the number of persistence IDs read and written per page
+	PageSize uint64
+	// ResumeToken restarts an interrupted migration after the last page it completed.
+	// It is the ResumeToken of the last reported Progress.
+	ResumeToken string
+	// OnProgress is called after every completed page
+	OnProgress func(Progress)
+}
+
+// Progress reports the advancement of a migration
+type Progress struct {
+	// Migrated is the number of states copied
+	Migrated int
+	// Skipped is the number of states not copied since the target holds the same or a newer version
+	Skipped int
+	// Missing is the number of listed persistence IDs without state in the source store
+	Missing int
+	// ResumeToken resumes the migration after the last completed page.
+	// It is empty once the migration is complete.
+	ResumeToken string
+}
+
+// Mismatch describes a state whose version differs between the source and the target
+type Mismatch struct {
+	PersistenceID string
+	SourceVersion uint64
+	// TargetVersion is zero when the state is missing from the target
+	TargetVersion uint64
+}
+
+// Report is the result of a verification pass
+type Report struct {
+	Checked    int
+	Mismatches []Mismatch
+}
+
+// Migrate copies the latest state of every persistence ID listed by ids from source to target.
+// A state is only written when the target holds an older version or none, so a migration can be
+// resumed or replayed safely, even once the entities are live in the target.
+func Migrate(ctx context.Context, source persistence.StateStore, ids PersistenceIDSource, target BatchWriter, config Config) (*Progress, error) {
+	pageSize := config.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	progress := &Progress{ResumeToken: config.ResumeToken}
+	for {
+		persistenceIDs, nextPageToken, err := ids.PersistenceIDs(ctx, pageSize, progress.ResumeToken)
+		if err != nil {
+			return progress, fmt.Errorf("failed to list the persistence ids: %w", err)
+		}
+
+		states := make([]*egopb.DurableState, 0, len(persistenceIDs))
+		for _, persistenceID := range persistenceIDs {
+			state, err := source.GetLatestState(ctx, persistenceID)
+			if err != nil {
+				return progress, fmt.Errorf("failed to read the state of persistenceID=%s: %w", persistenceID, err)
+			}
+			if state == nil {
+				progress.Missing++
+				continue
+			}
+			states = append(states, state)
+		}
+
+		if len(states) > 0 {
+			written, err := target.WriteStatesIfNewer(ctx, states)
+			progress.Migrated += written
+			if err != nil {
+				return progress, fmt.Errorf("failed to write the states: %w", err)
+			}
+			progress.Skipped += len(states) - written
+		}
+
+		progress.ResumeToken = nextPageToken
+		if config.OnProgress != nil {
+			config.OnProgress(*progress)
+		}
+
+		if nextPageToken == "" {
+			return progress, nil
+		}
+	}
+}
+
+// Verify compares the version of the latest state of every persistence ID listed by ids
+// between source and target and reports the differences
+func Verify(ctx context.Context, source, target persistence.StateStore, ids PersistenceIDSource, pageSize uint64) (*Report, error) {
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	report := new(Report)
+	var pageToken string
+	for {
+		persistenceIDs, nextPageToken, err := ids.PersistenceIDs(ctx, pageSize, pageToken)
+		if err != nil {
+			return report, fmt.Errorf("failed to list the persistence ids: %w", err)
+		}
+
+		for _, persistenceID := range persistenceIDs {
+			sourceState, sourceErr := source.GetLatestState(ctx, persistenceID)
+			targetState, targetErr := target.GetLatestState(ctx, persistenceID)
+			if err := errors.Join(sourceErr, targetErr); err != nil {
+				return report, fmt.Errorf("failed to read the state of persistenceID=%s: %w", persistenceID, err)
+			}
+			if sourceState == nil {
+				continue
+			}
+
+			report.Checked++
+			if sourceState.GetVersionNumber() != targetState.GetVersionNumber() {
+				report.Mismatches = append(report.Mismatches, Mismatch{
+					PersistenceID: persistenceID,
+					SourceVersion: sourceState.GetVersionNumber(),
+					TargetVersion: targetState.GetVersionNumber(),
+				})
+			}
+		}
+
+		if nextPageToken == "" {
+			return report, nil
+		}
+		pageToken = nextPageToken
+	}
+}
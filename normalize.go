@@ -0,0 +1,121 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// anyManifest is the manifest of the states stored as an anypb.Any by ProtoSerializer
+var anyManifest = string((&anypb.Any{}).ProtoReflect().Descriptor().FullName())
+
+// NormalizePayloads rewrites the states stored as a concrete message, for instance by another
+// writer, into the anypb.Any format written by ProtoSerializer, and returns how many were
+// rewritten. A state updated in the meantime is left untouched. Such states are readable
+// without normalization; it only makes the table uniform. The scan is measured as
+// IterateAllStates and the rewrites as WriteState.
+func (d *DynamoDurableStore) NormalizePayloads(ctx context.Context) (int, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		FilterExpression:         aws.String("#StateManifest <> :manifest AND attribute_not_exists(#DeletedAt)"),
+		ExpressionAttributeNames: d.schema.names("StateManifest", "DeletedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":manifest": &types.AttributeValueMemberS{Value: anyManifest},
+		},
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if prefix != "" {
+		input.FilterExpression = aws.String("#StateManifest <> :manifest AND attribute_not_exists(#DeletedAt) AND begins_with(#PersistenceID, :tenant)")
+		input.ExpressionAttributeNames = d.schema.names("StateManifest", "DeletedAt", "PersistenceID")
+		input.ExpressionAttributeValues[":tenant"] = &types.AttributeValueMemberS{Value: prefix}
+	}
+
+	normalized := 0
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationIterateAllStates, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationIterateAllStates, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return normalized, fmt.Errorf("failed to scan the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return normalized, err
+			}
+			rewritten, err := d.normalizePayload(ctx, item, prefix)
+			if err != nil {
+				return normalized, err
+			}
+			if rewritten {
+				normalized++
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return normalized, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// normalizePayload rewrites a single item unless its version changed since it was scanned
+func (d *DynamoDurableStore) normalizePayload(ctx context.Context, stored *StateItem, prefix string) (bool, error) {
+	state, err := d.toDurableState(ctx, stored, prefix, OperationIterateAllStates)
+	if err != nil {
+		return false, err
+	}
+	item, err := d.newStateItem(ctx, state, OperationWriteState)
+	if err != nil {
+		return false, err
+	}
+	// the items of a hot persistence ID are rewritten under their write shard key
+	if stored.PersistenceID != item.PersistenceID {
+		item.shardKey = stored.PersistenceID
+	}
+
+	err = d.execute(ctx, OperationWriteState, func(ctx context.Context) error {
+		resp, err := d.client.Load().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(d.schema.TableName),
+			Item:                     d.stateAttributes(item),
+			ConditionExpression:      aws.String("#VersionNumber = :version AND attribute_not_exists(#DeletedAt)"),
+			ExpressionAttributeNames: d.schema.names("VersionNumber", "DeletedAt"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.FormatUint(stored.VersionNumber, 10)},
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationWriteState, resp.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &conditionFailed):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to rewrite the state of persistenceID=%s: %w", state.GetPersistenceId(), err)
+	default:
+		d.invalidateCache(item.PersistenceID)
+		return true, nil
+	}
+}
@@ -0,0 +1,467 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Option configures the DynamoDurableStore
+type Option func(store *DynamoDurableStore)
+
+// WithAWSConfig sets the AWS configuration the clients of the store are built from.
+// By default the configuration is loaded from the environment with config.LoadDefaultConfig.
+func WithAWSConfig(cfg aws.Config) Option {
+	return func(store *DynamoDurableStore) {
+		store.awsConfig = &cfg
+	}
+}
+
+// WithEndpoint overrides the DynamoDB and DynamoDB Streams endpoint, for instance to target
+// DynamoDB Local
+func WithEndpoint(endpoint string) Option {
+	return func(store *DynamoDurableStore) {
+		store.endpoint = endpoint
+	}
+}
+
+// WithMetrics sets the Metrics implementation used to record the latency, throughput,
+// item sizes, consumed capacity and throttling of every DynamoDB call.
+// Consumed capacity is only requested from DynamoDB when metrics or WithConsumedCapacity are set.
+func WithMetrics(metrics Metrics) Option {
+	return func(store *DynamoDurableStore) {
+		if metrics != nil {
+			store.metrics = metrics
+			store.capacity = true
+		}
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry DynamoDB calls failing with
+// throttling or server-side errors
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		store.retry = policy
+	}
+}
+
+// WithoutRetry disables the retry layer of the store. Only the AWS SDK retries remain.
+func WithoutRetry() Option {
+	return func(store *DynamoDurableStore) {
+		store.retry = RetryPolicy{MaxAttempts: 1}
+	}
+}
+
+// WithCompression compresses the state payload of every write with the given codec
+// when its size reaches minSize bytes. The codec is recorded in the PayloadCodec
+// attribute so items written without compression remain readable.
+func WithCompression(codec Compression, minSize int) Option {
+	return func(store *DynamoDurableStore) {
+		store.compression = codec
+		store.compressionMinSize = minSize
+	}
+}
+
+// WithS3Overflow enables the large-object mode: payloads bigger than threshold bytes
+// are written to the given S3 bucket under prefix and the item only stores a pointer
+// (bucket, key and ETag) to the object. DefaultOverflowThreshold is used when threshold
+// is not positive. Objects of superseded versions are not deleted by the store, use an
+// S3 lifecycle rule to expire them.
+func WithS3Overflow(bucket, prefix string, threshold int) Option {
+	return func(store *DynamoDurableStore) {
+		if threshold <= 0 {
+			threshold = DefaultOverflowThreshold
+		}
+		store.overflow = &s3Overflow{
+			bucket:    bucket,
+			prefix:    prefix,
+			threshold: threshold,
+		}
+	}
+}
+
+// WithKMSKeyARN enables client-side envelope encryption of the state payload.
+// Every write encrypts the payload with a data key generated by the given KMS key
+// and stores the wrapped data key in the EncryptedDataKey attribute. The payload is authenticated
+// with the partition key and version of the state, and the data key with the partition key as
+// KMS encryption context, so that a payload copied to another state does not decrypt.
+func WithKMSKeyARN(keyARN string) Option {
+	return func(store *DynamoDurableStore) {
+		store.encryption = &kmsEncryption{keyARN: keyARN}
+	}
+}
+
+// WithKMSKeyResolver enables client-side envelope encryption of the state payload like
+// WithKMSKeyARN, with the KMS key and encryption context chosen per persistence ID or tenant
+// by the resolver. Dedicated keys let the data of a single tenant be revoked by disabling its key.
+func WithKMSKeyResolver(resolver KMSKeyResolver) Option {
+	return func(store *DynamoDurableStore) {
+		store.encryption = &kmsEncryption{resolver: resolver}
+	}
+}
+
+// WithOutboxTable sets the table storing the outbox messages written by WriteStateWithOutbox.
+// The table must have MessageID (String) as partition key and a global secondary index
+// named PendingIndex, the value of OutboxPendingIndex, with Pending (String) as partition key
+// and CreatedAt (Number) as sort key.
+func WithOutboxTable(name string) Option {
+	return func(store *DynamoDurableStore) {
+		store.outboxTable = name
+	}
+}
+
+// WithDAXClient routes GetLatestState through the given DAX cluster client while writes
+// keep using DynamoDB. When DAX fails to serve a read, the read is retried against DynamoDB
+// and DAX is bypassed for a short cooldown period.
+func WithDAXClient(client ItemReader) Option {
+	return func(store *DynamoDurableStore) {
+		if client != nil {
+			store.dax = &daxReader{client: client}
+		}
+	}
+}
+
+// WithCache enables a read-through in-memory LRU cache of at most size latest states,
+// each kept for at most ttl (forever when ttl is zero). Writes made through the store update
+// the cache; a cached state is never replaced by an older version. Writes made by other
+// processes are only observed once the cached entry expires.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		if size > 0 {
+			store.cache = newStateCache(size, ttl)
+		}
+	}
+}
+
+// WithSerializer sets the Serializer converting durable states to and from the stored
+// payload. The default ProtoSerializer resolves manifests from protoregistry.GlobalTypes.
+func WithSerializer(serializer Serializer) Option {
+	return func(store *DynamoDurableStore) {
+		if serializer != nil {
+			store.serializer = serializer
+		}
+	}
+}
+
+// WithTenant scopes every read, write and listing of the store to the given tenant so that
+// several tenants can share one table. The partition key of the items is prefixed by the
+// tenant ID, which must not contain the '#' separator.
+func WithTenant(tenantID string) Option {
+	return WithTenantResolver(func(context.Context) (string, error) {
+		return tenantID, nil
+	})
+}
+
+// WithTenantResolver scopes every call of the store to the tenant returned by the resolver,
+// for instance a tenant ID carried by the context. Calls fail when the tenant cannot be resolved.
+func WithTenantResolver(resolver TenantResolver) Option {
+	return func(store *DynamoDurableStore) {
+		store.tenant = resolver
+	}
+}
+
+// WithTimeout bounds the duration of every DynamoDB call without a more specific timeout.
+// A hung call is then cancelled, and retried according to the retry policy.
+func WithTimeout(timeout time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.timeouts.Default = timeout
+	}
+}
+
+// WithReadTimeout bounds the duration of every DynamoDB call reading the table
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.timeouts.Read = timeout
+	}
+}
+
+// WithWriteTimeout bounds the duration of every DynamoDB call modifying the table
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.timeouts.Write = timeout
+	}
+}
+
+// WithHealthCacheTTL sets how long the result of Ping and Health is reused before the table
+// is described again. DefaultHealthCacheTTL is used by default.
+func WithHealthCacheTTL(ttl time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.health.ttl = ttl
+	}
+}
+
+// WithWriteBehind enables the write-behind mode: WriteState buffers the states in memory and
+// returns immediately, and the buffer is persisted with BatchWriteItem every flushInterval or
+// as soon as it holds maxItems states. Successive writes of a persistence ID between two
+// flushes are coalesced into the latest version, and reads made through the store see the
+// buffered states. DeleteState and WriteStateWithOutbox flush the buffer before writing.
+//
+// This trades durability for throughput: buffered states are lost if the process crashes
+// before they are flushed, and background flush failures are not reported to the writers;
+// the failed states stay buffered and are retried with the next flush. Call Flush or
+// Disconnect to persist the buffer synchronously and observe failures.
+func WithWriteBehind(flushInterval time.Duration, maxItems int) Option {
+	return func(store *DynamoDurableStore) {
+		if flushInterval > 0 {
+			store.writeBehind = newWriteBehind(flushInterval, maxItems)
+		}
+	}
+}
+
+// WithIdempotentWrites makes WriteState and WriteStateWithOutbox use TransactWriteItems with a
+// client request token derived from the persistence ID and the version. A write retried within
+// ten minutes, for instance after a network timeout, is then applied at most once, and writing
+// a different state under an already written version fails instead of silently overwriting it.
+// A retried state is encoded anew, with a new UpdatedAt and, when encrypted, a new data key, so
+// DynamoDB rejects its token: the stored state is then read back and compared once decoded.
+// Transactional writes consume twice the write capacity of PutItem.
+func WithIdempotentWrites() Option {
+	return func(store *DynamoDurableStore) {
+		store.idempotentWrites = true
+	}
+}
+
+// WithSoftDelete makes DeleteState write a DeletedAt tombstone instead of deleting the item.
+// Soft-deleted states are hidden from reads and listings, can be restored with Restore, and
+// are hard-deleted by ReapTombstones or RunTombstoneReaper once retention has elapsed.
+func WithSoftDelete(retention time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.softDelete = retention
+	}
+}
+
+// WithLogger sets the Logger receiving structured entries about slow calls, retries,
+// conditional check failures and unprocessed batch items. *slog.Logger can be used directly.
+func WithLogger(logger Logger) Option {
+	return func(store *DynamoDurableStore) {
+		if logger != nil {
+			store.logger = logger
+		}
+	}
+}
+
+// WithSlowOperationThreshold sets the duration above which a DynamoDB call is logged as slow.
+// DefaultSlowOperationThreshold is used by default, zero disables the slow call entries.
+func WithSlowOperationThreshold(threshold time.Duration) Option {
+	return func(store *DynamoDurableStore) {
+		store.slowThreshold = threshold
+	}
+}
+
+// WithRegions deploys the store on a DynamoDB global table replicated across the given regions.
+// Writes go to the primary region and reads fail over to the replica regions, in the given
+// order, when the primary region fails to serve them.
+func WithRegions(primary string, replicas ...string) Option {
+	return func(store *DynamoDurableStore) {
+		store.regions = &multiRegion{primary: primary, replicas: replicas}
+	}
+}
+
+// WithLocalReads pins the reads to the given region of the global table to lower their latency.
+// Reads from a replica region are eventually consistent. It requires WithRegions.
+func WithLocalReads(region string) Option {
+	return func(store *DynamoDurableStore) {
+		store.localRegion = region
+	}
+}
+
+// WithMaxReadRate caps the number of DynamoDB read calls per second made by the store.
+// Calls above the rate wait for the rate limiter instead of being throttled by DynamoDB.
+func WithMaxReadRate(perSecond float64) Option {
+	return func(store *DynamoDurableStore) {
+		if perSecond > 0 {
+			store.readLimiter = newRateLimiter(perSecond)
+		}
+	}
+}
+
+// WithMaxWriteRate caps the number of DynamoDB write calls per second made by the store,
+// for instance to smooth the bursts of actors recovering at the same time.
+func WithMaxWriteRate(perSecond float64) Option {
+	return func(store *DynamoDurableStore) {
+		if perSecond > 0 {
+			store.writeLimiter = newRateLimiter(perSecond)
+		}
+	}
+}
+
+// WithCircuitBreaker makes the store fail fast with ErrCircuitOpen once DynamoDB answered
+// policy.FailureThreshold consecutive calls with a server-side error or a timeout, instead of
+// letting callers pile up on a failing region. See DefaultCircuitBreakerPolicy.
+func WithCircuitBreaker(policy CircuitBreakerPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		if policy.FailureThreshold > 0 {
+			store.breaker = &circuitBreaker{policy: policy}
+		}
+	}
+}
+
+// WithHistory records every version written by WriteState and WriteStateWithOutbox in the given
+// table, keyed by PersistenceID and VersionNumber, in the same transaction as the state itself.
+// The retention bounds the number of versions kept per persistence ID.
+func WithHistory(table string, retention HistoryRetention) Option {
+	return func(store *DynamoDurableStore) {
+		store.history = &history{table: table, retention: retention}
+	}
+}
+
+// WithReadOnly makes every operation modifying the table, such as WriteState and DeleteState,
+// fail with ErrReadOnly while reads work normally. It guards the tools pointed at a production
+// table against accidental writes.
+func WithReadOnly() Option {
+	return func(store *DynamoDurableStore) {
+		store.readOnly = true
+	}
+}
+
+// WithSchema maps the state items onto a table following other naming conventions: table name,
+// partition key, optional sort key and attribute names. The fields left empty keep the values
+// of DefaultSchema.
+func WithSchema(schema Schema) Option {
+	return func(store *DynamoDurableStore) {
+		if schema.TableName == "" {
+			schema.TableName = DefaultSchema.TableName
+		}
+		if schema.PartitionKey == "" {
+			schema.PartitionKey = DefaultSchema.PartitionKey
+		}
+		store.schema = schema
+	}
+}
+
+// WithConsumedCapacity requests the consumed capacity of every DynamoDB call and reports it to
+// the given callback, which may be nil. The capacity is also accumulated into the tracker of
+// contexts created with WithCapacityTracker, for per-entity cost attribution.
+func WithConsumedCapacity(callback CapacityCallback) Option {
+	return func(store *DynamoDurableStore) {
+		store.capacity = true
+		store.capacityCallback = callback
+	}
+}
+
+// WithAPIOptions attaches custom AWS SDK middleware to the DynamoDB clients built by the store,
+// for instance to inject headers, audit the requests or intercept them in tests
+func WithAPIOptions(apiOptions ...func(*middleware.Stack) error) Option {
+	return func(store *DynamoDurableStore) {
+		store.apiOptions = append(store.apiOptions, apiOptions...)
+	}
+}
+
+// WithWriteSharding spreads the writes of the persistence IDs for which hot returns true over
+// the given number of partition keys, to stay below the per-partition throughput limit.
+// Reads of a hot persistence ID fetch all its keys in parallel and keep the newest version.
+// The scan-based APIs, such as ListPersistenceIDs and IterateAllStates, report a hot
+// persistence ID once, with its newest version, fetching all its keys when they come across it.
+func WithWriteSharding(shards int, hot func(persistenceID string) bool) Option {
+	return func(store *DynamoDurableStore) {
+		if shards > 1 && hot != nil {
+			store.sharding = &writeSharding{shards: shards, hot: hot}
+		}
+	}
+}
+
+// WithValidation verifies every state before it is written, failing the write with ErrItemTooLarge
+// or ErrInvalidState instead of the opaque DynamoDB validation error or an unreadable item
+func WithValidation(policy ValidationPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		store.validation = &policy
+	}
+}
+
+// WithAutoScaling makes EnsureTable create the states table and its global secondary indexes
+// with provisioned capacity, starting at the minimum capacity, and configure Application Auto
+// Scaling target tracking policies for them. The billing mode of an existing table is left unchanged.
+func WithAutoScaling(policy AutoScaling) Option {
+	return func(store *DynamoDurableStore) {
+		store.autoScaling = &autoScaling{policy: policy}
+	}
+}
+
+// WithWriteDedupe skips the writes repeating the last version written for a persistence ID, as
+// ego does across passivation and reactivation cycles. The last version written is remembered
+// for up to size persistence IDs; for the others the write is conditional and leaves an item
+// already stored at the same version untouched.
+func WithWriteDedupe(size int) Option {
+	return func(store *DynamoDurableStore) {
+		if size > 0 {
+			store.dedupe = newWriteDedupe(size)
+		}
+	}
+}
+
+// WithPayloadChecksum stores a checksum of the marshalled state, computed with the given
+// algorithm, in the PayloadChecksum attribute. Reads verify the checksum, whatever the
+// configured algorithm, and fail with ErrCorruptPayload on mismatch.
+func WithPayloadChecksum(algorithm Checksum) Option {
+	return func(store *DynamoDurableStore) {
+		store.checksum = algorithm
+	}
+}
+
+// WithWarmUp makes Connect resolve the AWS credentials, size the HTTP connection pool and open
+// connections to DynamoDB upfront, so that the first actor recoveries after a deployment do
+// not pay the cold start latency. The eGo engine calls Connect when it starts.
+func WithWarmUp(warmUp WarmUp) Option {
+	return func(store *DynamoDurableStore) {
+		store.warmUpConfig = &warmUp
+	}
+}
+
+// WithClock sets the clock the store reads the time from for the timestamps it writes and its
+// retention cutoffs, for instance to freeze the time in tests. The system clock is used by default.
+func WithClock(clock Clock) Option {
+	return func(store *DynamoDurableStore) {
+		if clock != nil {
+			store.clock = clock
+		}
+	}
+}
+
+// WithHedgedReads makes GetLatestState issue a second read of the item when the first one has
+// not answered within the policy delay, and return the first response, cutting the tail
+// latency of the actor recoveries. The hedges are limited to a share of the reads.
+func WithHedgedReads(policy HedgePolicy) Option {
+	return func(store *DynamoDurableStore) {
+		if policy.Delay > 0 {
+			store.hedging = newHedging(policy)
+		}
+	}
+}
+
+// WithAudit appends an audit entry to the audit table within the same transaction as every
+// WriteState, WriteStateWithOutbox and DeleteState, recording the persistence ID, version,
+// operation, time and the caller information given with WithAuditContext. The writes buffered
+// by the write-behind mode and the WriteStates bulk loads are not audited, nor the deletions of
+// a missing state. Combined with WithIdempotentWrites, the entry of a write is appended right
+// after its transaction instead, so that a retried transaction does not differ by the entry.
+func WithAudit(policy AuditPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		if policy.Table != "" {
+			store.audit = &audit{policy: policy}
+		}
+	}
+}
+
+// WithPriorityQueue limits the DynamoDB calls of the store to the policy rate and, while calls
+// are waiting, serves their classes in proportion to the policy weights, so that the recovery
+// reads bringing actors online win over the background scans. It supersedes WithMaxReadRate and
+// WithMaxWriteRate. Metrics implementing QueueMetrics receive the depth of the queue.
+func WithPriorityQueue(policy PriorityPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		if policy.Rate > 0 {
+			store.priority = newPriorityQueue(policy)
+		}
+	}
+}
+
+// WithSnapshots enables the snapshots of the event-sourced entities in the policy table, see
+// SaveSnapshot, LatestSnapshot and the snapshotstore package wrapping an events journal
+func WithSnapshots(policy SnapshotPolicy) Option {
+	return func(store *DynamoDurableStore) {
+		if policy.Table != "" {
+			store.snapshots = &policy
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// OutboxPendingIndex is the sparse global secondary index of the outbox table
+	// listing the messages not yet delivered
+	OutboxPendingIndex = "PendingIndex"
+
+	// outboxPending is the value of the Pending attribute of undelivered messages, prefixed by
+	// the tenant ID in multi-tenant mode so that the relays of a tenant only list its messages
+	outboxPending = "PENDING"
+	// maxTransactItems is the maximum number of actions of a DynamoDB transaction
+	maxTransactItems = 100
+)
+
+// OutboxMessage is an integration message recorded atomically with a durable state
+type OutboxMessage struct {
+	// MessageID uniquely identifies the message within its tenant. It is derived from the
+	// persistence ID, the state version and the position of the message so that retried writes
+	// are idempotent.
+	MessageID     string
+	PersistenceID string
+	VersionNumber uint64
+	Message       *anypb.Any
+	CreatedAt     int64
+}
+
+// WriteStateWithOutbox persists the durable state and appends the given messages to the
+// outbox table in a single transaction: either both the state and the messages are
+// written or none of them.
+func (d *DynamoDurableStore) WriteStateWithOutbox(ctx context.Context, state *egopb.DurableState, messages []proto.Message) error {
+	if d.outboxTable == "" {
+		return errors.New("outbox table is not configured")
+	}
+	reserved := 1
+	if d.history != nil {
+		reserved++
+	}
+	if !d.idempotentWrites {
+		reserved += d.auditActionCount()
+	}
+	if len(messages)+reserved > maxTransactItems {
+		return fmt.Errorf("too many outbox messages: a transaction accepts at most %d", maxTransactItems-reserved)
+	}
+
+	// an older buffered write must not overwrite the state written with the outbox
+	if err := d.Flush(ctx); err != nil {
+		return err
+	}
+
+	item, err := d.newStateItem(ctx, state, OperationWriteOutbox)
+	if err != nil {
+		return err
+	}
+
+	if d.sharding.isHot(state.GetPersistenceId()) {
+		item.shardKey = d.sharding.shardKey(item.PersistenceID, item.VersionNumber)
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+	createdAt := d.clock.Now().UnixMilli()
+	outboxActions := make([]types.TransactWriteItem, 0, len(messages))
+	for index, message := range messages {
+		anyMessage, err := anypb.New(message)
+		if err != nil {
+			return fmt.Errorf("failed to pack the outbox message: %w", err)
+		}
+		outboxMessage := &OutboxMessage{
+			MessageID:     state.GetPersistenceId() + "/" + strconv.FormatUint(state.GetVersionNumber(), 10) + "/" + strconv.Itoa(index),
+			PersistenceID: state.GetPersistenceId(),
+			VersionNumber: state.GetVersionNumber(),
+			Message:       anyMessage,
+			CreatedAt:     createdAt,
+		}
+		attributes, err := marshalOutboxMessage(outboxMessage, prefix)
+		if err != nil {
+			return err
+		}
+		outboxActions = append(outboxActions, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.outboxTable),
+				Item:      attributes,
+			},
+		})
+	}
+
+	write := func(audit *auditAppend) error {
+		if audit != nil {
+			item.AuditSignature = audit.signature
+		}
+		actions := make([]types.TransactWriteItem, 0, len(messages)+reserved)
+		actions = append(actions, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.schema.TableName),
+				Item:      d.stateAttributes(item),
+			},
+		})
+		if d.history != nil {
+			actions = append(actions, types.TransactWriteItem{Put: d.historyPut(item)})
+		}
+		if audit != nil {
+			actions = append(actions, audit.actions()...)
+		}
+		actions = append(actions, outboxActions...)
+
+		input := &dynamodb.TransactWriteItemsInput{
+			TransactItems:          actions,
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		}
+		if d.idempotentWrites {
+			input.ClientRequestToken = aws.String(clientRequestToken(item.PersistenceID, item.VersionNumber))
+		}
+		err := d.execute(ctx, OperationWriteOutbox, func(ctx context.Context) error {
+			resp, err := d.client.Load().TransactWriteItems(ctx, input)
+			if err == nil {
+				for index := range resp.ConsumedCapacity {
+					d.observeConsumedCapacity(ctx, OperationWriteOutbox, &resp.ConsumedCapacity[index])
+				}
+			}
+			return err
+		})
+		if d.idempotentWrites {
+			err = d.idempotentMismatch(ctx, item, err)
+		}
+		return err
+	}
+
+	// the audit entry of an idempotent transaction is appended once it succeeded, see writeStateTransaction
+	if d.idempotentWrites {
+		err = write(nil)
+	} else {
+		err = d.appendAudited(ctx, item.PersistenceID, item.VersionNumber, OperationWriteState, write)
+	}
+	if err != nil {
+		d.invalidateCache(item.PersistenceID)
+		return fmt.Errorf("failed to write state and outbox messages into the dynamodb: %w", err)
+	}
+
+	d.updateCache(item.PersistenceID, state)
+	if d.idempotentWrites {
+		return d.appendAudited(ctx, item.PersistenceID, item.VersionNumber, OperationWriteState, func(audit *auditAppend) error {
+			return d.putAudit(ctx, audit, stateKey(item), item.VersionNumber, OperationWriteOutbox)
+		})
+	}
+	return nil
+}
+
+// PendingOutboxMessages returns up to limit outbox messages not yet delivered, oldest first.
+// In multi-tenant mode, only the messages of the tenant of the call are returned.
+func (d *DynamoDurableStore) PendingOutboxMessages(ctx context.Context, limit int32) ([]*OutboxMessage, error) {
+	if d.outboxTable == "" {
+		return nil, errors.New("outbox table is not configured")
+	}
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dynamodb.QueryOutput
+	err = d.execute(ctx, OperationPollOutbox, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(d.outboxTable),
+			IndexName:              aws.String(OutboxPendingIndex),
+			KeyConditionExpression: aws.String("Pending = :pending"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pending": &types.AttributeValueMemberS{Value: prefix + outboxPending},
+			},
+			ScanIndexForward:       aws.Bool(true),
+			Limit:                  aws.Int32(limit),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationPollOutbox, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending outbox messages from the dynamodb: %w", err)
+	}
+
+	messages := make([]*OutboxMessage, 0, len(resp.Items))
+	for _, attributes := range resp.Items {
+		message, err := unmarshalOutboxMessage(attributes, prefix)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// MarkOutboxDelivered flags the given outbox message of the tenant of the call as delivered so
+// that it is no longer returned by PendingOutboxMessages. Marking a delivered message again is a no-op.
+func (d *DynamoDurableStore) MarkOutboxDelivered(ctx context.Context, messageID string) error {
+	if d.outboxTable == "" {
+		return errors.New("outbox table is not configured")
+	}
+	key, err := d.outboxKey(ctx, messageID)
+	if err != nil {
+		return err
+	}
+
+	err = d.execute(ctx, OperationMarkDelivered, func(ctx context.Context) error {
+		resp, err := d.client.Load().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String(d.outboxTable),
+			Key:                 key,
+			UpdateExpression:    aws.String("REMOVE Pending, LeaseUntil SET DeliveredAt = :now"),
+			ConditionExpression: aws.String("attribute_exists(Pending)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(d.clock.Now().UnixMilli(), 10)},
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationMarkDelivered, resp.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message=%s as delivered: %w", messageID, err)
+	}
+	return nil
+}
+
+// ClaimOutboxMessage leases the given pending outbox message of the tenant of the call for the
+// given duration, so that concurrent relays do not publish it twice. It returns false when the
+// message has already been delivered or is leased by another relay. The lease expires on its
+// own when the relay holding it fails before marking the message delivered.
+func (d *DynamoDurableStore) ClaimOutboxMessage(ctx context.Context, messageID string, lease time.Duration) (bool, error) {
+	if d.outboxTable == "" {
+		return false, errors.New("outbox table is not configured")
+	}
+
+	key, err := d.outboxKey(ctx, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	now := d.clock.Now()
+	err = d.execute(ctx, OperationClaimOutbox, func(ctx context.Context) error {
+		resp, err := d.client.Load().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String(d.outboxTable),
+			Key:                 key,
+			UpdateExpression:    aws.String("SET LeaseUntil = :leaseUntil"),
+			ConditionExpression: aws.String("attribute_exists(Pending) AND (attribute_not_exists(LeaseUntil) OR LeaseUntil < :now)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":leaseUntil": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(lease).UnixMilli(), 10)},
+				":now":        &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationClaimOutbox, resp.ConsumedCapacity)
+		}
+		return err
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox message=%s: %w", messageID, err)
+	}
+	return true, nil
+}
+
+// outboxKey returns the key of the given outbox message of the tenant of the call
+func (d *DynamoDurableStore) outboxKey(ctx context.Context, messageID string) (map[string]types.AttributeValue, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"MessageID": &types.AttributeValueMemberS{Value: prefix + messageID},
+	}, nil
+}
+
+// marshalOutboxMessage converts an outbox message of the tenant with the given partition key
+// prefix into its DynamoDB attributes
+func marshalOutboxMessage(message *OutboxMessage, prefix string) (map[string]types.AttributeValue, error) {
+	payload, err := proto.Marshal(message.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the outbox message: %w", err)
+	}
+
+	return map[string]types.AttributeValue{
+		"MessageID":     &types.AttributeValueMemberS{Value: prefix + message.MessageID}, // Partition key
+		"PersistenceID": &types.AttributeValueMemberS{Value: message.PersistenceID},
+		"VersionNumber": &types.AttributeValueMemberN{Value: strconv.FormatUint(message.VersionNumber, 10)},
+		"Message":       &types.AttributeValueMemberB{Value: payload},
+		"CreatedAt":     &types.AttributeValueMemberN{Value: strconv.FormatInt(message.CreatedAt, 10)},
+		"Pending":       &types.AttributeValueMemberS{Value: prefix + outboxPending},
+	}, nil
+}
+
+// unmarshalOutboxMessage converts DynamoDB attributes into an outbox message of the tenant
+// with the given partition key prefix
+func unmarshalOutboxMessage(attributes map[string]types.AttributeValue, prefix string) (*OutboxMessage, error) {
+	messageID, err := persistenceIDOf(prefix, parseDynamoString(attributes["MessageID"]))
+	if err != nil {
+		return nil, err
+	}
+
+	message := new(anypb.Any)
+	if err := proto.Unmarshal(parseDynamoBytes(attributes["Message"]), message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the outbox message: %w", err)
+	}
+
+	version, err := parseDynamoUint64(attributes["VersionNumber"])
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := parseDynamoInt64(attributes["CreatedAt"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		MessageID:     messageID,
+		PersistenceID: parseDynamoString(attributes["PersistenceID"]),
+		VersionNumber: version,
+		Message:       message,
+		CreatedAt:     createdAt,
+	}, nil
+}
@@ -0,0 +1,50 @@
+// Package kafkapublisher publishes outbox messages to Kafka
+package kafkapublisher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+	"github.com/sdil/ego-dynamodb-durablestore/outboxrelay"
+)
+
+// Publisher publishes outbox messages to Kafka. The value of a record is the serialized
+// google.protobuf.Any of the message and its key is the persistence ID, so that the messages
+// of an entity land in the same partition in order. The message ID and type URL are sent as headers.
+type Publisher struct {
+	writer *kafka.Writer
+}
+
+// enforce interface implementation
+var _ outboxrelay.Publisher = (*Publisher)(nil)
+
+// New creates a Publisher writing with the given writer, which defines the topic and the
+// acknowledgement level. Use kafka.RequireAll for the messages to survive a broker failure.
+func New(writer *kafka.Writer) *Publisher {
+	return &Publisher{writer: writer}
+}
+
+// Publish writes the message to Kafka
+func (p *Publisher) Publish(ctx context.Context, message *dynamodb.OutboxMessage) error {
+	payload, err := proto.Marshal(message.Message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the outbox message: %w", err)
+	}
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(message.PersistenceID),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "MessageID", Value: []byte(message.MessageID)},
+			{Key: "TypeURL", Value: []byte(message.Message.GetTypeUrl())},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write to kafka: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package outboxrelay publishes the messages of the transactional outbox of the DynamoDB
+// durable store, see WriteStateWithOutbox, to a message broker such as Kafka or SNS.
+//
+// A message is leased before it is published and marked delivered afterwards, both through
+// conditional updates, so that concurrent relays do not publish it twice. A relay failing
+// between the publication and the acknowledgement leaves the message pending: it is published
+// again once its lease expires. Consumers deduplicate on the message ID.
+package outboxrelay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+)
+
+const (
+	// DefaultPollInterval is the delay between two polls of an empty outbox when none is configured
+	DefaultPollInterval = time.Second
+	// DefaultBatchSize is the number of messages fetched per poll when none is configured
+	DefaultBatchSize = 100
+	// DefaultLease is how long a message is reserved for the relay publishing it when none is configured
+	DefaultLease = 30 * time.Second
+)
+
+// Outbox gives access to the pending outbox messages. The DynamoDB durable store implements it.
+type Outbox interface {
+	PendingOutboxMessages(ctx context.Context, limit int32) ([]*dynamodb.OutboxMessage, error)
+	ClaimOutboxMessage(ctx context.Context, messageID string, lease time.Duration) (bool, error)
+	MarkOutboxDelivered(ctx context.Context, messageID string) error
+}
+
+// Publisher delivers an outbox message to a message broker. Publish returns once the broker
+// has acknowledged the message.
+type Publisher interface {
+	Publish(ctx context.Context, message *dynamodb.OutboxMessage) error
+}
+
+// Config configures a relay
+type Config struct {
+	// PollInterval is the delay between two polls of an empty outbox
+	PollInterval time.Duration
+	// BatchSize is the number of messages fetched per poll
+	BatchSize int32
+	// Lease is how long a message is reserved for the relay publishing it. It must exceed the
+	// time needed to publish a message.
+	Lease time.Duration
+	// OnError is called with the errors Run recovers from, it may be nil
+	OnError func(error)
+}
+
+// Relay polls the outbox and publishes the pending messages
+type Relay struct {
+	outbox    Outbox
+	publisher Publisher
+	config    Config
+}
+
+// New creates a relay publishing the messages of the outbox with the given publisher
+func New(outbox Outbox, publisher Publisher, config Config) *Relay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.Lease <= 0 {
+		config.Lease = DefaultLease
+	}
+	return &Relay{outbox: outbox, publisher: publisher, config: config}
+}
+
+// Run relays the pending messages until the context is done. The outbox is polled again right
+// away after a full batch, and after the poll interval otherwise. Failures are reported to
+// OnError and retried at the next poll.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		relayed, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil && r.config.OnError != nil {
+			r.config.OnError(err)
+		}
+
+		delay := r.config.PollInterval
+		if err == nil && relayed == int(r.config.BatchSize) {
+			delay = 0
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// RelayOnce publishes a batch of pending messages, oldest first, and returns how many were
+// published. It stops at the first failure, and skips the later messages of an entity whose
+// message is claimed by another relay, so that the messages of an entity are not published
+// out of order.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.outbox.PendingOutboxMessages(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	relayed := 0
+	// the persistence IDs whose later messages wait for a message claimed by another relay
+	blocked := make(map[string]bool)
+	for _, message := range messages {
+		if blocked[message.PersistenceID] {
+			continue
+		}
+		claimed, err := r.outbox.ClaimOutboxMessage(ctx, message.MessageID, r.config.Lease)
+		if err != nil {
+			return relayed, err
+		}
+		if !claimed {
+			// delivered or being published by another relay, which may die holding the lease:
+			// the later messages of the entity are left to a next batch
+			blocked[message.PersistenceID] = true
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, message); err != nil {
+			return relayed, fmt.Errorf("failed to publish outbox message=%s: %w", message.MessageID, err)
+		}
+		if err := r.outbox.MarkOutboxDelivered(ctx, message.MessageID); err != nil {
+			return relayed, err
+		}
+		relayed++
+	}
+	return relayed, nil
+}
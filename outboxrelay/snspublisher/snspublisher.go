@@ -0,0 +1,74 @@
+// Package snspublisher publishes outbox messages to an Amazon SNS topic
+package snspublisher
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"google.golang.org/protobuf/proto"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+	"github.com/sdil/ego-dynamodb-durablestore/outboxrelay"
+)
+
+// Publisher publishes outbox messages to an SNS topic. The body of a notification is the
+// base64-encoded google.protobuf.Any of the message; the message ID, persistence ID and type
+// URL are sent as message attributes. On a FIFO topic the messages of an entity share a message
+// group and are deduplicated on their message ID.
+type Publisher struct {
+	client   *sns.Client
+	topicARN string
+	fifo     bool
+}
+
+// enforce interface implementation
+var _ outboxrelay.Publisher = (*Publisher)(nil)
+
+// New creates a Publisher to the given topic
+func New(client *sns.Client, topicARN string) *Publisher {
+	return &Publisher{
+		client:   client,
+		topicARN: topicARN,
+		fifo:     strings.HasSuffix(topicARN, ".fifo"),
+	}
+}
+
+// Publish publishes the message to the topic
+func (p *Publisher) Publish(ctx context.Context, message *dynamodb.OutboxMessage) error {
+	payload, err := proto.Marshal(message.Message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the outbox message: %w", err)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(base64.StdEncoding.EncodeToString(payload)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"MessageID":     stringAttribute(message.MessageID),
+			"PersistenceID": stringAttribute(message.PersistenceID),
+			"TypeURL":       stringAttribute(message.Message.GetTypeUrl()),
+		},
+	}
+	if p.fifo {
+		input.MessageGroupId = aws.String(message.PersistenceID)
+		input.MessageDeduplicationId = aws.String(message.MessageID)
+	}
+
+	if _, err := p.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish to topic=%s: %w", p.topicARN, err)
+	}
+	return nil
+}
+
+// stringAttribute returns a string message attribute
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
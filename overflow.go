@@ -0,0 +1,73 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultOverflowThreshold is the payload size above which states are offloaded to S3
+// when no threshold is given. It leaves room below the 400KB DynamoDB item limit for
+// the other attributes of the item.
+const DefaultOverflowThreshold = 350 * 1024
+
+// s3Overflow holds the large-object mode configuration
+type s3Overflow struct {
+	client    atomic.Pointer[s3.Client]
+	bucket    string
+	prefix    string
+	threshold int
+}
+
+// s3Pointer locates a state payload stored in S3
+type s3Pointer struct {
+	Bucket string
+	Key    string
+	ETag   string
+}
+
+// put uploads the payload of the given state version and returns its location.
+// Every version gets its own object so that a failed DynamoDB write never
+// corrupts the payload referenced by the current item.
+func (o *s3Overflow) put(ctx context.Context, persistenceID string, version uint64, payload []byte) (*s3Pointer, error) {
+	key := o.prefix + persistenceID + "/" + strconv.FormatUint(version, 10)
+	resp, err := o.client.Load().PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(payload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload the state payload to s3: %w", err)
+	}
+
+	return &s3Pointer{
+		Bucket: o.bucket,
+		Key:    key,
+		ETag:   aws.ToString(resp.ETag),
+	}, nil
+}
+
+// get downloads the payload referenced by the pointer, making sure it has not been replaced
+func (o *s3Overflow) get(ctx context.Context, pointer *s3Pointer) ([]byte, error) {
+	resp, err := o.client.Load().GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(pointer.Bucket),
+		Key:     aws.String(pointer.Key),
+		IfMatch: aws.String(pointer.ETag),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the state payload from s3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the state payload from s3: %w", err)
+	}
+	return payload, nil
+}
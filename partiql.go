@@ -0,0 +1,153 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// QueryStates runs a PartiQL SELECT statement against the states table and returns the
+// matching durable states, for ad-hoc operational queries such as
+//
+//	SELECT * FROM "states_store" WHERE ShardNumber = ? AND VersionNumber > ?
+//
+// The statement must select whole items. The parameters replace the ? placeholders in order;
+// strings, byte slices, booleans, integers, floats and types.AttributeValue are accepted.
+// Soft-deleted states and the states of other tenants are left out. Statements reading another
+// table than the states table, or one of its indexes, are rejected.
+func (d *DynamoDurableStore) QueryStates(ctx context.Context, partiql string, params ...any) ([]*egopb.DurableState, error) {
+	if err := d.validateStatement(partiql); err != nil {
+		return nil, err
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make([]types.AttributeValue, 0, len(params))
+	for _, param := range params {
+		value, err := toAttributeValue(param)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, value)
+	}
+
+	input := &dynamodb.ExecuteStatementInput{
+		Statement:              aws.String(partiql),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if len(parameters) > 0 {
+		input.Parameters = parameters
+	}
+
+	var states []*egopb.DurableState
+	latest := newLatestVersions()
+	for {
+		var resp *dynamodb.ExecuteStatementOutput
+		err := d.execute(ctx, OperationQueryStates, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().ExecuteStatement(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationQueryStates, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute the statement: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return nil, err
+			}
+			if item.DeletedAt > 0 || !strings.HasPrefix(item.PersistenceID, prefix) {
+				continue
+			}
+			latestCopy, err := d.isLatestCopy(ctx, latest, item.PersistenceID, item.VersionNumber)
+			if err != nil {
+				return nil, err
+			}
+			if !latestCopy {
+				continue
+			}
+			state, err := d.toDurableState(ctx, item, prefix, OperationQueryStates)
+			if err != nil {
+				return nil, err
+			}
+			states = append(states, state)
+		}
+
+		if resp.NextToken == nil {
+			return states, nil
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+// statementSource matches the table a PartiQL SELECT statement reads from, quoted or not,
+// optionally followed by one of its indexes
+var statementSource = regexp.MustCompile(`(?is)^\s*SELECT\s.*?\bFROM\s+("(?:[^"]|"")+"|[A-Za-z0-9_.-]+)`)
+
+// validateStatement checks that the statement is a SELECT reading the states table
+func (d *DynamoDurableStore) validateStatement(partiql string) error {
+	fields := strings.Fields(partiql)
+	if len(fields) == 0 {
+		return errors.New("empty statement")
+	}
+	if !strings.EqualFold(fields[0], "SELECT") {
+		return errors.New("only SELECT statements are supported")
+	}
+
+	match := statementSource.FindStringSubmatch(partiql)
+	if match == nil {
+		return errors.New("statement has no FROM clause")
+	}
+	table := match[1]
+	if unquoted, ok := strings.CutPrefix(table, `"`); ok {
+		table = strings.ReplaceAll(strings.TrimSuffix(unquoted, `"`), `""`, `"`)
+	} else {
+		table, _, _ = strings.Cut(table, ".")
+	}
+	if table != d.schema.TableName {
+		return fmt.Errorf("statement reads table=%s instead of the states table=%s", table, d.schema.TableName)
+	}
+	return nil
+}
+
+// toAttributeValue converts a PartiQL statement parameter into its DynamoDB attribute value
+func toAttributeValue(param any) (types.AttributeValue, error) {
+	switch value := param.(type) {
+	case types.AttributeValue:
+		return value, nil
+	case string:
+		return &types.AttributeValueMemberS{Value: value}, nil
+	case []byte:
+		return &types.AttributeValueMemberB{Value: value}, nil
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: value}, nil
+	case int:
+		return &types.AttributeValueMemberN{Value: strconv.Itoa(value)}, nil
+	case int32:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(int64(value), 10)}, nil
+	case int64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(value, 10)}, nil
+	case uint32:
+		return &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(value), 10)}, nil
+	case uint64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatUint(value, 10)}, nil
+	case float64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported statement parameter type %T", param)
+	}
+}
@@ -0,0 +1,34 @@
+package dynamodb
+
+import "testing"
+
+func TestValidateStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		wantErr   bool
+	}{
+		{name: "empty", statement: "", wantErr: true},
+		{name: "whitespace only", statement: " \t\n", wantErr: true},
+		{name: "not a select", statement: `DELETE FROM "states_store" WHERE "PersistenceID" = ?`, wantErr: true},
+		{name: "quoted states table", statement: `SELECT * FROM "states_store" WHERE "PersistenceID" = ?`},
+		{name: "unquoted states table", statement: `select * from states_store`},
+		{name: "states table index", statement: `SELECT * FROM "states_store"."ShardIndex" WHERE "Shard" = ?`},
+		{name: "other table", statement: `SELECT * FROM "states_store_history"`, wantErr: true},
+		{name: "other table named like an index", statement: `SELECT * FROM states_store_audit.ShardIndex`, wantErr: true},
+		{name: "no from clause", statement: `SELECT *`, wantErr: true},
+	}
+
+	store := NewStateStore()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := store.validateStatement(test.statement)
+			if test.wantErr && err == nil {
+				t.Fatalf("validateStatement(%q) = nil, want an error", test.statement)
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("validateStatement(%q) = %v", test.statement, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+)
+
+// encodePayload applies the configured compression, encryption and S3 overflow to the
+// marshalled state and records the result into the item
+func (d *DynamoDurableStore) encodePayload(ctx context.Context, item *StateItem, payload []byte) error {
+	if d.checksum != NoChecksum {
+		sum, err := checksum(d.checksum, payload)
+		if err != nil {
+			return err
+		}
+		item.PayloadChecksum = sum
+	}
+
+	if d.compression != NoCompression && len(payload) >= d.compressionMinSize {
+		compressed, err := compress(d.compression, payload)
+		if err != nil {
+			return fmt.Errorf("failed to compress the durable state: %w", err)
+		}
+		payload, item.PayloadCodec = compressed, string(d.compression)
+	}
+
+	if d.encryption != nil {
+		key, aad, err := d.encryptionKey(ctx, item)
+		if err != nil {
+			return err
+		}
+		ciphertext, wrappedKey, err := d.encryption.encrypt(ctx, key, payload, aad)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the durable state: %w", err)
+		}
+		payload, item.EncryptedDataKey = ciphertext, wrappedKey
+	}
+
+	if d.overflow != nil && len(payload) > d.overflow.threshold {
+		pointer, err := d.overflow.put(ctx, item.PersistenceID, item.VersionNumber, payload)
+		if err != nil {
+			return err
+		}
+		item.PayloadBucket, item.PayloadKey, item.PayloadETag = pointer.Bucket, pointer.Key, pointer.ETag
+		return nil
+	}
+
+	item.StatePayload = payload
+	return nil
+}
+
+// decodePayload reverses encodePayload and returns the marshalled state of the item
+func (d *DynamoDurableStore) decodePayload(ctx context.Context, item *StateItem) ([]byte, error) {
+	payload := item.StatePayload
+	if item.PayloadKey != "" {
+		if d.overflow == nil {
+			return nil, fmt.Errorf("state of persistenceID=%s is stored in s3 but the s3 overflow is not configured", item.PersistenceID)
+		}
+		var err error
+		payload, err = d.overflow.get(ctx, &s3Pointer{
+			Bucket: item.PayloadBucket,
+			Key:    item.PayloadKey,
+			ETag:   item.PayloadETag,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(item.EncryptedDataKey) > 0 {
+		if d.encryption == nil {
+			return nil, fmt.Errorf("state of persistenceID=%s is encrypted but no kms key is configured", item.PersistenceID)
+		}
+		key, aad, err := d.encryptionKey(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := d.encryption.decrypt(ctx, key, payload, item.EncryptedDataKey, aad)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the durable state: %w", err)
+		}
+		payload = plaintext
+	}
+
+	payload, err := decompress(Compression(item.PayloadCodec), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress the durable state: %w", withClass(ErrCorruptPayload, err))
+	}
+	if item.PayloadChecksum != "" {
+		if err := verifyChecksum(item.PayloadChecksum, payload); err != nil {
+			return nil, fmt.Errorf("failed to verify the durable state of persistenceID=%s: %w", item.PersistenceID, err)
+		}
+	}
+	return payload, nil
+}
@@ -0,0 +1,216 @@
+package dynamodb
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OperationClass groups the operations competing for the calls allowed by the priority queue
+type OperationClass string
+
+const (
+	// ClassRecoveryRead holds the reads bringing actors online: GetLatestState and Ping
+	ClassRecoveryRead OperationClass = "recovery-read"
+	// ClassWrite holds the operations modifying the table
+	ClassWrite OperationClass = "write"
+	// ClassScan holds the other reads: scans, queries, projections and maintenance jobs
+	ClassScan OperationClass = "scan"
+)
+
+// DefaultPriorityWeights favours the recovery reads over the writes, and both over the scans
+var DefaultPriorityWeights = map[OperationClass]int{
+	ClassRecoveryRead: 8,
+	ClassWrite:        4,
+	ClassScan:         1,
+}
+
+// PriorityPolicy configures the priority queue shared by the DynamoDB calls of the store
+type PriorityPolicy struct {
+	// Rate is the number of DynamoDB calls allowed per second, all classes included
+	Rate float64
+	// Weights is the share of the calls each class gets while several classes are waiting.
+	// A missing class weighs one. It defaults to DefaultPriorityWeights.
+	Weights map[OperationClass]int
+}
+
+// QueueMetrics is implemented by the Metrics that also record the depth of the priority queue,
+// to let the callers apply backpressure upstream
+type QueueMetrics interface {
+	// ObserveQueueDepth records the number of calls of the given class waiting in the priority queue
+	ObserveQueueDepth(class OperationClass, depth int)
+}
+
+// recoveryReads lists the operations of ClassRecoveryRead
+var recoveryReads = map[string]bool{
+	OperationGetLatestState: true,
+	OperationPing:           true,
+}
+
+// classOf returns the class of the given operation
+func classOf(operation string) OperationClass {
+	switch {
+	case recoveryReads[operation]:
+		return ClassRecoveryRead
+	case writeOperations[operation]:
+		return ClassWrite
+	default:
+		return ClassScan
+	}
+}
+
+// priorityWaiter is a call waiting in the priority queue
+type priorityWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// priorityQueue is a token bucket handing its tokens to the waiting calls with a smooth
+// weighted round robin over their classes. It holds up to one second worth of tokens, and at
+// least one so that a rate below one call per second still lets the calls through.
+type priorityQueue struct {
+	mu        sync.Mutex
+	rate      float64
+	tokens    float64
+	last      time.Time
+	weights   map[OperationClass]int
+	current   map[OperationClass]int
+	waiters   map[OperationClass][]*priorityWaiter
+	scheduled bool
+	observe   func(class OperationClass, depth int)
+}
+
+// newPriorityQueue creates the priority queue of the given policy
+func newPriorityQueue(policy PriorityPolicy) *priorityQueue {
+	weights := policy.Weights
+	if weights == nil {
+		weights = DefaultPriorityWeights
+	}
+	return &priorityQueue{
+		rate:    policy.Rate,
+		tokens:  policy.Rate,
+		last:    time.Now(),
+		weights: weights,
+		current: make(map[OperationClass]int),
+		waiters: make(map[OperationClass][]*priorityWaiter),
+		observe: func(OperationClass, int) {},
+	}
+}
+
+// wait blocks until a call of the given class is allowed or the context is done, and returns
+// how long it waited
+func (q *priorityQueue) wait(ctx context.Context, class OperationClass) (time.Duration, error) {
+	q.mu.Lock()
+	q.refill()
+	if len(q.waiters) == 0 && q.tokens >= 1 {
+		q.tokens--
+		q.mu.Unlock()
+		return 0, nil
+	}
+
+	start := time.Now()
+	waiter := &priorityWaiter{ready: make(chan struct{})}
+	q.waiters[class] = append(q.waiters[class], waiter)
+	q.observe(class, len(q.waiters[class]))
+	q.schedule()
+	q.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if waiter.granted {
+			// the token was handed over while the caller stopped waiting
+			q.tokens++
+		} else {
+			q.remove(class, waiter)
+		}
+		return time.Since(start), ctx.Err()
+	}
+}
+
+// depth returns the number of calls of the given class waiting in the queue
+func (q *priorityQueue) depth(class OperationClass) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters[class])
+}
+
+// refill adds the tokens earned since the last refill. The lock must be held.
+func (q *priorityQueue) refill() {
+	now := time.Now()
+	q.tokens = min(max(q.rate, 1), q.tokens+now.Sub(q.last).Seconds()*q.rate)
+	q.last = now
+}
+
+// schedule runs dispatch once the next token is available, unless it is already scheduled.
+// The lock must be held.
+func (q *priorityQueue) schedule() {
+	if q.scheduled || len(q.waiters) == 0 {
+		return
+	}
+	q.scheduled = true
+	delay := time.Duration(max(0, 1-q.tokens) / q.rate * float64(time.Second))
+	time.AfterFunc(delay, q.dispatch)
+}
+
+// dispatch hands the available tokens to the waiting calls
+func (q *priorityQueue) dispatch() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.scheduled = false
+	q.refill()
+	for q.tokens >= 1 && len(q.waiters) > 0 {
+		class := q.next()
+		waiter := q.waiters[class][0]
+		q.remove(class, waiter)
+		waiter.granted = true
+		close(waiter.ready)
+		q.tokens--
+	}
+	q.schedule()
+}
+
+// next picks the class served next with a smooth weighted round robin over the waiting
+// classes. The lock must be held.
+func (q *priorityQueue) next() OperationClass {
+	var (
+		best  OperationClass
+		total int
+	)
+	for class := range q.waiters {
+		weight := max(q.weights[class], 1)
+		q.current[class] += weight
+		total += weight
+		if best == "" || q.current[class] > q.current[best] {
+			best = class
+		}
+	}
+	q.current[best] -= total
+	return best
+}
+
+// remove takes the waiter out of the queue of its class. The lock must be held.
+func (q *priorityQueue) remove(class OperationClass, waiter *priorityWaiter) {
+	waiters := slices.DeleteFunc(q.waiters[class], func(w *priorityWaiter) bool { return w == waiter })
+	if len(waiters) == 0 {
+		delete(q.waiters, class)
+		delete(q.current, class)
+	} else {
+		q.waiters[class] = waiters
+	}
+	q.observe(class, len(waiters))
+}
+
+// QueueDepth returns the number of calls of the given class waiting in the priority queue,
+// zero when the priority queue is disabled. Callers can shed or delay work while it grows.
+func (d *DynamoDurableStore) QueueDepth(class OperationClass) int {
+	if d.priority == nil {
+		return 0
+	}
+	return d.priority.depth(class)
+}
@@ -0,0 +1,21 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueueBelowOneCallPerSecond(t *testing.T) {
+	queue := newPriorityQueue(PriorityPolicy{Rate: 0.5})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	waited, err := queue.wait(ctx, ClassWrite)
+	if err != nil {
+		t.Fatalf("wait failed after %s: %v", waited, err)
+	}
+	if waited < 500*time.Millisecond {
+		t.Fatalf("waited %s, want about a second for the first token", waited)
+	}
+}
@@ -0,0 +1,298 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// RebuildOptions configures RebuildProjection
+type RebuildOptions struct {
+	// Name identifies the projection. Its checkpoints are stored under this name.
+	Name string
+	// CheckpointTable is the table the checkpoints are stored in. Its partition key is the
+	// ProjectionID string attribute.
+	CheckpointTable string
+	// Segments is the number of segments of the parallel scan, one by default.
+	// Changing it discards the checkpoints of a previous run.
+	Segments int
+	// Workers is the number of goroutines calling the handler, Segments by default
+	Workers int
+	// MaxRate caps the number of states handled per second, zero means no limit
+	MaxRate float64
+	// Restart ignores the checkpoints of a previous run and replays every state
+	Restart bool
+}
+
+// projectionTask is a state handed over to a projection worker
+type projectionTask struct {
+	state *egopb.DurableState
+	done  func()
+}
+
+// projectionRebuild holds the state of a running RebuildProjection
+type projectionRebuild struct {
+	store    *DynamoDurableStore
+	options  RebuildOptions
+	prefix   string
+	handler  func(*egopb.DurableState) error
+	limiter  *rateLimiter
+	latest   *latestVersions
+	tasks    []chan projectionTask
+	cancel   context.CancelFunc
+	once     sync.Once
+	firstErr error
+}
+
+// RebuildProjection replays the latest state of every persistence ID into the handler, for
+// instance to rebuild a read model. The table is read with a parallel scan and the states are
+// dispatched to the workers by persistence ID, so that the states of a persistence ID are
+// always handled in order by the same worker. A hot persistence ID, see WithWriteSharding, is
+// handled once with its newest version. The progress of every segment is checkpointed
+// in the checkpoint table once a page has been handled: a rebuild interrupted by an error or
+// a cancellation resumes from its checkpoints, and states may therefore be handled twice.
+func (d *DynamoDurableStore) RebuildProjection(ctx context.Context, handler func(*egopb.DurableState) error, opts RebuildOptions) error {
+	if opts.Name == "" || opts.CheckpointTable == "" {
+		return errors.New("projection name and checkpoint table are required")
+	}
+	opts.Segments = max(opts.Segments, 1)
+	if opts.Workers <= 0 {
+		opts.Workers = opts.Segments
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rebuild := &projectionRebuild{
+		store:   d,
+		options: opts,
+		prefix:  prefix,
+		handler: handler,
+		latest:  newLatestVersions(),
+		tasks:   make([]chan projectionTask, opts.Workers),
+		cancel:  cancel,
+	}
+	if opts.MaxRate > 0 {
+		rebuild.limiter = newRateLimiter(opts.MaxRate)
+	}
+
+	var workers sync.WaitGroup
+	for worker := range rebuild.tasks {
+		rebuild.tasks[worker] = make(chan projectionTask)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			rebuild.work(ctx, rebuild.tasks[worker])
+		}()
+	}
+
+	var segments sync.WaitGroup
+	for segment := 0; segment < opts.Segments; segment++ {
+		segments.Add(1)
+		go func() {
+			defer segments.Done()
+			if err := rebuild.scanSegment(ctx, int32(segment)); err != nil {
+				rebuild.fail(err)
+			}
+		}()
+	}
+	segments.Wait()
+
+	for _, tasks := range rebuild.tasks {
+		close(tasks)
+	}
+	workers.Wait()
+	if rebuild.firstErr != nil {
+		return rebuild.firstErr
+	}
+	return ctx.Err()
+}
+
+// fail records the first error of the rebuild and stops it
+func (r *projectionRebuild) fail(err error) {
+	r.once.Do(func() {
+		r.firstErr = err
+		r.cancel()
+	})
+}
+
+// work calls the handler for every task until the tasks channel is closed
+func (r *projectionRebuild) work(ctx context.Context, tasks <-chan projectionTask) {
+	for task := range tasks {
+		if ctx.Err() == nil {
+			if err := r.handle(ctx, task.state); err != nil {
+				r.fail(err)
+			}
+		}
+		task.done()
+	}
+}
+
+// handle calls the handler for a single state within the rate limit
+func (r *projectionRebuild) handle(ctx context.Context, state *egopb.DurableState) error {
+	if r.limiter != nil {
+		if _, err := r.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if err := r.handler(state); err != nil {
+		return fmt.Errorf("failed to project the state of persistenceID=%s: %w", state.GetPersistenceId(), err)
+	}
+	return nil
+}
+
+// dispatch hands the state over to the worker of its persistence ID
+func (r *projectionRebuild) dispatch(ctx context.Context, state *egopb.DurableState, done func()) error {
+	worker := fnv32a(fnvOffset32, state.GetPersistenceId()) % uint32(len(r.tasks))
+	select {
+	case r.tasks[worker] <- projectionTask{state: state, done: done}:
+		return nil
+	case <-ctx.Done():
+		done()
+		return ctx.Err()
+	}
+}
+
+// scanSegment scans the given segment from its checkpoint and dispatches its states page by page
+func (r *projectionRebuild) scanSegment(ctx context.Context, segment int32) error {
+	d := r.store
+	checkpointID := r.prefix + r.options.Name + "/" + strconv.Itoa(int(segment)) + "/" + strconv.Itoa(r.options.Segments)
+
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(d.schema.TableName),
+		Segment:                aws.Int32(segment),
+		TotalSegments:          aws.Int32(int32(r.options.Segments)),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, r.prefix)
+
+	if !r.options.Restart {
+		startKey, completed, err := d.loadCheckpoint(ctx, r.options.CheckpointTable, checkpointID)
+		if err != nil {
+			return err
+		}
+		if completed {
+			return nil
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationRebuildProjection, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationRebuildProjection, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan segment=%d of the dynamodb: %w", segment, err)
+		}
+
+		var page sync.WaitGroup
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return err
+			}
+			if item.DeletedAt > 0 {
+				continue
+			}
+			latestCopy, err := d.isLatestCopy(ctx, r.latest, item.PersistenceID, item.VersionNumber)
+			if err != nil {
+				return err
+			}
+			if !latestCopy {
+				continue
+			}
+			state, err := d.toDurableState(ctx, item, r.prefix, OperationRebuildProjection)
+			if err != nil {
+				return err
+			}
+			page.Add(1)
+			if err := r.dispatch(ctx, state, page.Done); err != nil {
+				break
+			}
+		}
+		page.Wait()
+		// the checkpoint only moves forward once the whole page has been handled
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := d.saveCheckpoint(ctx, r.options.CheckpointTable, checkpointID, resp.LastEvaluatedKey); err != nil {
+			return err
+		}
+		if resp.LastEvaluatedKey == nil {
+			return nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// loadCheckpoint returns the scan position of a projection segment and whether it is completed
+func (d *DynamoDurableStore) loadCheckpoint(ctx context.Context, table, checkpointID string) (map[string]types.AttributeValue, bool, error) {
+	var resp *dynamodb.GetItemOutput
+	err := d.execute(ctx, OperationRebuildProjection, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(table),
+			Key: map[string]types.AttributeValue{
+				"ProjectionID": &types.AttributeValueMemberS{Value: checkpointID},
+			},
+			ConsistentRead: aws.Bool(true),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load the checkpoint=%s: %w", checkpointID, err)
+	}
+
+	if resp.Item == nil {
+		return nil, false, nil
+	}
+	if completed, ok := resp.Item["Completed"].(*types.AttributeValueMemberBOOL); ok && completed.Value {
+		return nil, true, nil
+	}
+	if startKey, ok := resp.Item["ExclusiveStartKey"].(*types.AttributeValueMemberM); ok {
+		return startKey.Value, false, nil
+	}
+	return nil, false, nil
+}
+
+// saveCheckpoint records the scan position of a projection segment, a nil start key marking
+// the segment as completed
+func (d *DynamoDurableStore) saveCheckpoint(ctx context.Context, table, checkpointID string, startKey map[string]types.AttributeValue) error {
+	item := map[string]types.AttributeValue{
+		"ProjectionID": &types.AttributeValueMemberS{Value: checkpointID},
+		"Completed":    &types.AttributeValueMemberBOOL{Value: startKey == nil},
+		"UpdatedAt":    &types.AttributeValueMemberN{Value: strconv.FormatInt(d.clock.Now().UnixMilli(), 10)},
+	}
+	if startKey != nil {
+		item["ExclusiveStartKey"] = &types.AttributeValueMemberM{Value: startKey}
+	}
+
+	err := d.execute(ctx, OperationSaveCheckpoint, func(ctx context.Context) error {
+		_, err := d.client.Load().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(table),
+			Item:      item,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the checkpoint=%s: %w", checkpointID, err)
+	}
+	return nil
+}
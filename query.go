@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// GetStatesByShard returns a page of at most pageSize durable states belonging to the given
+// shard, ordered by persistence ID. An empty pageToken fetches the first page; the returned
+// nextPageToken is empty once the last page has been reached.
+// The table must have the ShardIndex global secondary index, see EnsureTable.
+func (d *DynamoDurableStore) GetStatesByShard(ctx context.Context, shard uint64, pageSize int32, pageToken string) (states []*egopb.DurableState, nextPageToken string, err error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	shardValue := &types.AttributeValueMemberN{Value: strconv.FormatUint(shard, 10)}
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(d.schema.TableName),
+		IndexName:                aws.String(ShardIndex),
+		KeyConditionExpression:   aws.String("#ShardNumber = :shard"),
+		ExpressionAttributeNames: d.schema.names("ShardNumber"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":shard": shardValue,
+		},
+		Limit:                  aws.Int32(pageSize),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if prefix != "" {
+		input.KeyConditionExpression = aws.String("#ShardNumber = :shard AND begins_with(#PersistenceID, :tenant)")
+		input.ExpressionAttributeNames = d.schema.names("ShardNumber", "PersistenceID")
+		input.ExpressionAttributeValues[":tenant"] = &types.AttributeValueMemberS{Value: prefix}
+	}
+	if pageToken != "" {
+		input.ExclusiveStartKey = d.schema.key(pageToken)
+		input.ExclusiveStartKey[d.schema.attr("ShardNumber")] = shardValue
+	}
+
+	var resp *dynamodb.QueryOutput
+	err = d.execute(ctx, OperationGetStatesByShard, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Query(ctx, input)
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationGetStatesByShard, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch the states of shard=%d from the dynamodb: %w", shard, err)
+	}
+
+	latest := newLatestVersions()
+	states = make([]*egopb.DurableState, 0, len(resp.Items))
+	for _, attributes := range resp.Items {
+		item, err := d.schema.unmarshal(attributes)
+		if err != nil {
+			return nil, "", err
+		}
+		if item.DeletedAt > 0 {
+			continue
+		}
+		latestCopy, err := d.isLatestCopy(ctx, latest, item.PersistenceID, item.VersionNumber)
+		if err != nil {
+			return nil, "", err
+		}
+		if !latestCopy {
+			continue
+		}
+		state, err := d.toDurableState(ctx, item, prefix, OperationGetStatesByShard)
+		if err != nil {
+			return nil, "", err
+		}
+		states = append(states, state)
+	}
+
+	if resp.LastEvaluatedKey != nil {
+		nextPageToken = parseDynamoString(resp.LastEvaluatedKey[d.schema.PartitionKey])
+	}
+	return states, nextPageToken, nil
+}
+
+// ListPersistenceIDs returns a page of at most pageSize persistence IDs stored in the table.
+// An empty pageToken fetches the first page; the returned nextPageToken is empty once the
+// whole table has been scanned. The order of the persistence IDs is unspecified.
+func (d *DynamoDurableStore) ListPersistenceIDs(ctx context.Context, pageSize int32, pageToken string) (persistenceIDs []string, nextPageToken string, err error) {
+	return d.scanPersistenceIDs(ctx, nil, pageSize, pageToken)
+}
+
+// ListPersistenceIDsSegment is the parallel variant of ListPersistenceIDs: it pages through
+// the given segment of a table split into totalSegments. Running one goroutine per segment
+// enumerates the whole table concurrently. Page tokens are only valid for their segment.
+func (d *DynamoDurableStore) ListPersistenceIDsSegment(ctx context.Context, segment, totalSegments int32, pageSize int32, pageToken string) (persistenceIDs []string, nextPageToken string, err error) {
+	if segment < 0 || segment >= totalSegments {
+		return nil, "", fmt.Errorf("invalid segment=%d for totalSegments=%d", segment, totalSegments)
+	}
+	return d.scanPersistenceIDs(ctx, &scanSegment{segment: segment, total: totalSegments}, pageSize, pageToken)
+}
+
+// scanSegment identifies a segment of a parallel scan
+type scanSegment struct {
+	segment int32
+	total   int32
+}
+
+// scanPersistenceIDs scans a page of persistence IDs, optionally restricted to a segment
+func (d *DynamoDurableStore) scanPersistenceIDs(ctx context.Context, segment *scanSegment, pageSize int32, pageToken string) ([]string, string, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber, #DeletedAt"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber", "DeletedAt"),
+		Limit:                    aws.Int32(pageSize),
+		ReturnConsumedCapacity:   d.returnConsumedCapacity(),
+	}
+	if segment != nil {
+		input.Segment = aws.Int32(segment.segment)
+		input.TotalSegments = aws.Int32(segment.total)
+	}
+	if pageToken != "" {
+		input.ExclusiveStartKey = d.schema.key(pageToken)
+	}
+	d.schema.applyTenantFilter(input, prefix)
+
+	var resp *dynamodb.ScanOutput
+	err = d.execute(ctx, OperationListPersistenceIDs, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Scan(ctx, input)
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationListPersistenceIDs, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch the persistence ids from the dynamodb: %w", err)
+	}
+
+	latest := newLatestVersions()
+	persistenceIDs := make([]string, 0, len(resp.Items))
+	for _, attributes := range resp.Items {
+		if parseDynamoOptionalInt64(attributes[d.schema.attr("DeletedAt")]) > 0 {
+			continue
+		}
+		partitionKey := parseDynamoString(attributes[d.schema.PartitionKey])
+		version, err := parseDynamoUint64(attributes[d.schema.attr("VersionNumber")])
+		if err != nil {
+			return nil, "", err
+		}
+		latestCopy, err := d.isLatestCopy(ctx, latest, partitionKey, version)
+		if err != nil {
+			return nil, "", err
+		}
+		if !latestCopy {
+			continue
+		}
+		persistenceID, err := persistenceIDOf(prefix, partitionKey)
+		if err != nil {
+			return nil, "", err
+		}
+		persistenceID = d.sharding.unshard(persistenceID)
+		persistenceIDs = append(persistenceIDs, persistenceID)
+	}
+
+	var nextPageToken string
+	if resp.LastEvaluatedKey != nil {
+		nextPageToken = parseDynamoString(resp.LastEvaluatedKey[d.schema.PartitionKey])
+	}
+	return persistenceIDs, nextPageToken, nil
+}
+
+// FindPersistenceIDs returns a page of at most limit persistence IDs starting with the given
+// prefix, for instance "order-2024-". An empty pageToken fetches the first page; the returned
+// nextPageToken is empty once the whole table has been searched. The search is a scan filtered
+// on the partition key: it reads the whole table over the successive pages but only transfers
+// the matching persistence IDs. The limit must be positive.
+func (d *DynamoDurableStore) FindPersistenceIDs(ctx context.Context, prefix string, limit int32, pageToken string) (persistenceIDs []string, nextPageToken string, err error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("invalid limit=%d: it must be positive", limit)
+	}
+	tenantPrefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(d.schema.TableName),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber"),
+		FilterExpression:         aws.String("begins_with(#PersistenceID, :prefix) AND attribute_not_exists(#DeletedAt)"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber", "DeletedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: tenantPrefix + prefix},
+		},
+		Limit:                  aws.Int32(limit),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	if pageToken != "" {
+		input.ExclusiveStartKey = d.schema.key(pageToken)
+	}
+
+	latest := newLatestVersions()
+	for {
+		var resp *dynamodb.ScanOutput
+		err = d.execute(ctx, OperationFindPersistenceIDs, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationFindPersistenceIDs, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to search the persistence ids in the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			partitionKey := parseDynamoString(attributes[d.schema.PartitionKey])
+			version, err := parseDynamoUint64(attributes[d.schema.attr("VersionNumber")])
+			if err != nil {
+				return nil, "", err
+			}
+			// a hot persistence ID is reported once, for its item holding the newest version
+			latestCopy, err := d.isLatestCopy(ctx, latest, partitionKey, version)
+			if err != nil {
+				return nil, "", err
+			}
+			if !latestCopy {
+				continue
+			}
+			persistenceID, err := persistenceIDOf(tenantPrefix, partitionKey)
+			if err != nil {
+				return nil, "", err
+			}
+			persistenceIDs = append(persistenceIDs, d.sharding.unshard(persistenceID))
+			if int32(len(persistenceIDs)) == limit {
+				// the next page resumes the scan after the last returned item
+				return persistenceIDs, partitionKey, nil
+			}
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return persistenceIDs, "", nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,38 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindPersistenceIDsLimit(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newFakeStore(t)
+	for _, persistenceID := range []string{"order-1", "order-2"} {
+		if err := store.WriteState(ctx, testState(t, persistenceID, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, limit := range []int32{0, -1} {
+		if _, _, err := store.FindPersistenceIDs(ctx, "order-", limit, ""); err == nil {
+			t.Fatalf("FindPersistenceIDs with limit=%d succeeded, want an error", limit)
+		}
+	}
+
+	persistenceIDs, nextPageToken, err := store.FindPersistenceIDs(ctx, "order-", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persistenceIDs) != 1 || persistenceIDs[0] != "order-1" || nextPageToken == "" {
+		t.Fatalf("FindPersistenceIDs = %v, %q, want [order-1] and a next page", persistenceIDs, nextPageToken)
+	}
+
+	persistenceIDs, nextPageToken, err = store.FindPersistenceIDs(ctx, "order-", 1, nextPageToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persistenceIDs) != 1 || persistenceIDs[0] != "order-2" {
+		t.Fatalf("FindPersistenceIDs of the next page = %v, %q, want [order-2]", persistenceIDs, nextPageToken)
+	}
+}
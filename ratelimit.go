@@ -0,0 +1,82 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket smoothing the rate of the DynamoDB calls made by the store.
+// It holds up to one second worth of tokens so that short bursts are not delayed.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a rate limiter allowing the given number of calls per second
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{
+		rate:   perSecond,
+		tokens: perSecond,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a call is allowed or the context is done, and returns how long it waited
+func (l *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		l.cancel()
+		return delay, err
+	}
+	return delay, nil
+}
+
+// reserve takes a token from the bucket and returns how long the caller must wait for it
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel gives back a token reserved by a caller that stopped waiting
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// waitForCapacity delays a call of the given operation according to the priority queue, or
+// the configured read or write rate
+func (d *DynamoDurableStore) waitForCapacity(ctx context.Context, operation string) error {
+	if d.priority != nil {
+		wait, err := d.priority.wait(ctx, classOf(operation))
+		d.metrics.ObserveRateLimitWait(operation, wait)
+		return err
+	}
+
+	limiter := d.readLimiter
+	if writeOperations[operation] {
+		limiter = d.writeLimiter
+	}
+	if limiter == nil {
+		return nil
+	}
+
+	wait, err := limiter.wait(ctx)
+	d.metrics.ObserveRateLimitWait(operation, wait)
+	return err
+}
@@ -0,0 +1,9 @@
+package dynamodb
+
+// checkWritable returns ErrReadOnly when the given operation modifies the table of a read-only store
+func (d *DynamoDurableStore) checkWritable(operation string) error {
+	if d.readOnly && writeOperations[operation] {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package dynamodb
+
+import (
+	"maps"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// CurrentRecordVersion is the layout version of the items written by the store. The items
+// written before the layout was versioned have no RecordVersion attribute and are version 0.
+const CurrentRecordVersion = 2
+
+// recordUpgrade upgrades, in place, the attributes of an item from a layout version to the next one
+type recordUpgrade func(s Schema, attributes map[string]types.AttributeValue)
+
+// recordUpgrades holds the upgrade of every layout version older than CurrentRecordVersion,
+// keyed by the version it upgrades from. A change to the layout of the items bumps
+// CurrentRecordVersion and registers the upgrade from the previous version here, so that the
+// items already stored are still readable. They are rewritten in the current layout on their
+// next write.
+var recordUpgrades = map[uint32]recordUpgrade{
+	// version 1 introduces the RecordVersion attribute, the older items may hold a legacy Timestamp
+	0: upgradeLegacyTimestamp,
+	// version 2 binds the encrypted payloads to their partition key and version, see encryptionKey
+	1: func(Schema, map[string]types.AttributeValue) {},
+}
+
+// encryptionBindingRecordVersion is the first layout version binding the encrypted payloads to
+// their partition key and version
+const encryptionBindingRecordVersion = 2
+
+// upgradeLegacyTimestamp converts the Timestamp of the first items written by the store, a
+// string holding the rune of the timestamp, into a number. The rune of a timestamp beyond the
+// last code point is the replacement character: the timestamp is lost and the attribute dropped.
+// Decimal strings, written by other writers, are left to the numeric coercion of unmarshal.
+func upgradeLegacyTimestamp(s Schema, attributes map[string]types.AttributeValue) {
+	name := s.attr("Timestamp")
+	timestamp, ok := attributes[name].(*types.AttributeValueMemberS)
+	if !ok {
+		return
+	}
+	if _, err := strconv.ParseInt(timestamp.Value, 10, 64); err == nil {
+		return
+	}
+
+	r, size := utf8.DecodeRuneInString(timestamp.Value)
+	if r == utf8.RuneError || size != len(timestamp.Value) {
+		delete(attributes, name)
+		return
+	}
+	attributes[name] = &types.AttributeValueMemberN{Value: strconv.Itoa(int(r))}
+}
+
+// upgrade brings the attributes of an item to the current layout. The given attributes are
+// left untouched, the upgraded ones are returned along with the version the item was stored with.
+func (s Schema) upgrade(attributes map[string]types.AttributeValue) (map[string]types.AttributeValue, uint32) {
+	version := uint32(parseDynamoOptionalInt64(attributes[s.attr("RecordVersion")]))
+	if version >= CurrentRecordVersion {
+		return attributes, version
+	}
+
+	upgraded := maps.Clone(attributes)
+	for from := version; from < CurrentRecordVersion; from++ {
+		recordUpgrades[from](s, upgraded)
+	}
+	return upgraded, version
+}
@@ -0,0 +1,90 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// baselineTimestamp returns the Timestamp attribute the first versions of the store wrote,
+// the string of the rune of the timestamp
+func baselineTimestamp(timestamp int64) *types.AttributeValueMemberS {
+	return &types.AttributeValueMemberS{Value: string(rune(timestamp))}
+}
+
+func TestUnmarshalBaselineItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp types.AttributeValue
+		want      int64
+	}{
+		{name: "timestamp below the last code point", timestamp: baselineTimestamp(0x1F600), want: 0x1F600},
+		{name: "timestamp beyond the last code point", timestamp: baselineTimestamp(1_700_000_000), want: 0},
+		{name: "empty timestamp", timestamp: &types.AttributeValueMemberS{Value: ""}, want: 0},
+		{name: "decimal timestamp", timestamp: &types.AttributeValueMemberS{Value: "1700000000"}, want: 1_700_000_000},
+		{name: "numeric timestamp", timestamp: &types.AttributeValueMemberN{Value: "1700000000"}, want: 1_700_000_000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the baseline wrote neither VersionNumber, ShardNumber nor RecordVersion
+			item, err := DefaultSchema.unmarshal(map[string]types.AttributeValue{
+				"PersistenceID": &types.AttributeValueMemberS{Value: "order-1"},
+				"StatePayload":  &types.AttributeValueMemberB{Value: []byte{}},
+				"StateManifest": &types.AttributeValueMemberS{Value: anyManifest},
+				"Timestamp":     test.timestamp,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Timestamp != test.want {
+				t.Fatalf("Timestamp = %d, want %d", item.Timestamp, test.want)
+			}
+			if item.VersionNumber != 0 || item.RecordVersion != 0 {
+				t.Fatalf("VersionNumber = %d, RecordVersion = %d, want 0 and 0", item.VersionNumber, item.RecordVersion)
+			}
+		})
+	}
+}
+
+func TestReadBaselineItems(t *testing.T) {
+	store, fake := newFakeStore(t)
+	state, err := anypb.New(wrapperspb.String("order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.putItem(DefaultTableName, attributes{
+		"PersistenceID": {"S": "order-1"},
+		"StatePayload":  {"B": payload},
+		"StateManifest": {"S": anyManifest},
+		"Timestamp":     {"S": string(rune(42))},
+	})
+	ctx := context.Background()
+
+	got, err := store.GetLatestState(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("GetLatestState returned no state")
+	}
+	if got.GetTimestamp() != 42 || !proto.Equal(got.GetResultingState(), state) {
+		t.Fatalf("GetLatestState = %+v, want timestamp 42 and state %v", got, state)
+	}
+
+	persistenceIDs, _, err := store.ListPersistenceIDs(ctx, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persistenceIDs) != 1 || persistenceIDs[0] != "order-1" {
+		t.Fatalf("ListPersistenceIDs = %v, want [order-1]", persistenceIDs)
+	}
+}
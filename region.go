@@ -0,0 +1,60 @@
+package dynamodb
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// multiRegion routes the reads of a store deployed on a DynamoDB global table.
+// Writes always go to the primary region.
+type multiRegion struct {
+	primary  string
+	replicas []string
+	// local is the region reads are pinned to, empty to read from the primary region
+	local string
+	// clients holds the client of every region, replaced as a whole by Connect
+	clients atomic.Pointer[map[string]*dynamodb.Client]
+}
+
+// client returns the client of the given region
+func (m *multiRegion) client(region string) *dynamodb.Client {
+	return (*m.clients.Load())[region]
+}
+
+// readOrder returns the regions to read from, in order of preference
+func (m *multiRegion) readOrder() []string {
+	order := make([]string, 0, len(m.replicas)+1)
+	if m.local != "" {
+		order = append(order, m.local)
+	}
+	for _, region := range append([]string{m.primary}, m.replicas...) {
+		if region != m.local {
+			order = append(order, region)
+		}
+	}
+	return order
+}
+
+// regionalGetItem reads an item from the preferred region and fails over to the next
+// regions of the global table when a region fails to serve the request.
+// Reads served by a replica region are eventually consistent.
+func (d *DynamoDurableStore) regionalGetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if d.regions == nil {
+		return d.client.Load().GetItem(ctx, input)
+	}
+
+	var (
+		resp *dynamodb.GetItemOutput
+		err  error
+	)
+	for _, region := range d.regions.readOrder() {
+		resp, err = d.regions.client(region).GetItem(ctx, input)
+		if err == nil || ctx.Err() != nil {
+			return resp, err
+		}
+		d.logger.Warn("dynamodb read failed, failing over to the next region", "region", region, "error", err)
+	}
+	return resp, err
+}
@@ -0,0 +1,168 @@
+package dynamodb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultTableName is the name of the states table when no schema is configured
+const DefaultTableName = "states_store"
+
+// Schema maps the state items onto the layout of the states table, for instance to reuse
+// an existing table following other naming conventions
+type Schema struct {
+	// TableName is the name of the states table
+	TableName string
+	// PartitionKey is the attribute holding the persistence ID
+	PartitionKey string
+	// SortKey is the sort key attribute of a table with a composite primary key, empty otherwise.
+	// Every state item is written with SortKeyValue as sort key.
+	SortKey      string
+	SortKeyValue string
+	// Attributes renames the other attributes of the state items. It is keyed by their default
+	// names: VersionNumber, StatePayload, StateManifest, Timestamp, ShardNumber, PayloadCodec,
+	// PayloadBucket, PayloadKey, PayloadETag, PayloadChecksum, EncryptedDataKey, DeletedAt,
+	// UpdatedAt, UpdatedBucket and RecordVersion.
+	Attributes map[string]string
+}
+
+// stateAttributeCount is the largest number of attributes of a state item, sizing the
+// attribute maps upfront so that they are not grown while being filled
+const stateAttributeCount = 19
+
+// DefaultSchema is the layout of the tables created by EnsureTable when no schema is configured
+var DefaultSchema = Schema{
+	TableName:    DefaultTableName,
+	PartitionKey: "PersistenceID",
+}
+
+// attr returns the name of the given attribute in the table
+func (s Schema) attr(name string) string {
+	if name == "PersistenceID" {
+		return s.PartitionKey
+	}
+	if renamed, ok := s.Attributes[name]; ok && renamed != "" {
+		return renamed
+	}
+	return name
+}
+
+// names returns the expression attribute names of the given attributes. Expressions refer to
+// an attribute with its default name prefixed by #, for instance #VersionNumber.
+func (s Schema) names(attributes ...string) map[string]string {
+	names := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		names["#"+attribute] = s.attr(attribute)
+	}
+	return names
+}
+
+// key returns the primary key of the item with the given partition key
+func (s Schema) key(partitionKey string) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{
+		s.PartitionKey: &types.AttributeValueMemberS{Value: partitionKey},
+	}
+	if s.SortKey != "" {
+		key[s.SortKey] = &types.AttributeValueMemberS{Value: s.SortKeyValue}
+	}
+	return key
+}
+
+// keySchema returns the primary key definition of the states table
+func (s Schema) keySchema() []types.KeySchemaElement {
+	keySchema := []types.KeySchemaElement{
+		{AttributeName: aws.String(s.PartitionKey), KeyType: types.KeyTypeHash},
+	}
+	if s.SortKey != "" {
+		keySchema = append(keySchema, types.KeySchemaElement{AttributeName: aws.String(s.SortKey), KeyType: types.KeyTypeRange})
+	}
+	return keySchema
+}
+
+// stateAttributes maps the default names of the attributes of a state item, as in the struct
+// tags of StateItem, to whether they hold a number
+var stateAttributes = map[string]bool{
+	"PersistenceID":    false,
+	"VersionNumber":    true,
+	"StatePayload":     false,
+	"StateManifest":    false,
+	"Timestamp":        true,
+	"ShardNumber":      true,
+	"PayloadCodec":     false,
+	"PayloadBucket":    false,
+	"PayloadKey":       false,
+	"PayloadETag":      false,
+	"PayloadChecksum":  false,
+	"EncryptedDataKey": false,
+	"DeletedAt":        true,
+	"UpdatedAt":        true,
+	"RecordVersion":    true,
+	"AuditSignature":   false,
+}
+
+// marshal converts the item into its DynamoDB attributes. Optional attributes
+// are only written when set.
+func (s Schema) marshal(item *StateItem) map[string]types.AttributeValue {
+	record := *item
+	record.RecordVersion = CurrentRecordVersion
+	// MarshalMap cannot fail on a StateItem, which only holds strings, numbers and byte slices
+	encoded, _ := attributevalue.MarshalMap(&record)
+
+	attributes := make(map[string]types.AttributeValue, stateAttributeCount)
+	for name, value := range encoded {
+		attributes[s.attr(name)] = value
+	}
+	if s.SortKey != "" {
+		attributes[s.SortKey] = &types.AttributeValueMemberS{Value: s.SortKeyValue}
+	}
+	if item.UpdatedAt > 0 {
+		attributes[s.attr("UpdatedBucket")] = &types.AttributeValueMemberS{Value: updatedBucket(item.PersistenceID, item.UpdatedAt)}
+	}
+	return attributes
+}
+
+// unmarshal converts DynamoDB attributes into an item, upgrading the items stored in an older
+// layout. Numbers stored as strings, as some other writers do, are accepted; any other attribute
+// of an unexpected type fails with ErrCorruptPayload.
+func (s Schema) unmarshal(attributes map[string]types.AttributeValue) (*StateItem, error) {
+	attributes, recordVersion := s.upgrade(attributes)
+
+	named := make(map[string]types.AttributeValue, len(stateAttributes))
+	for name, numeric := range stateAttributes {
+		value, ok := attributes[s.attr(name)]
+		if !ok {
+			continue
+		}
+		if str, isString := value.(*types.AttributeValueMemberS); isString && numeric {
+			value = &types.AttributeValueMemberN{Value: str.Value}
+		}
+		named[name] = value
+	}
+
+	item := new(StateItem)
+	if err := attributevalue.UnmarshalMap(named, item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the state item: %w", withClass(ErrCorruptPayload, err))
+	}
+	item.RecordVersion = recordVersion
+	return item, nil
+}
+
+// applyTenantFilter restricts a scan to the items of the tenant with the given partition key prefix
+func (s Schema) applyTenantFilter(input *dynamodb.ScanInput, prefix string) {
+	if prefix == "" {
+		return
+	}
+	input.FilterExpression = aws.String("begins_with(#PersistenceID, :tenant)")
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = make(map[string]string)
+	}
+	input.ExpressionAttributeNames["#PersistenceID"] = s.PartitionKey
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = make(map[string]types.AttributeValue)
+	}
+	input.ExpressionAttributeValues[":tenant"] = &types.AttributeValueMemberS{Value: prefix}
+}
@@ -0,0 +1,73 @@
+package dynamodb
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Serializer converts durable states to and from the bytes stored in the StatePayload
+// attribute. The manifest returned by Marshal is stored in the StateManifest attribute
+// and handed back to Unmarshal.
+type Serializer interface {
+	// Marshal serializes the state and returns its manifest
+	Marshal(state *anypb.Any) (manifest string, payload []byte, err error)
+	// Unmarshal deserializes a payload given its manifest
+	Unmarshal(manifest string, payload []byte) (*anypb.Any, error)
+}
+
+// ProtoSerializer is the default Serializer. It stores the protobuf wire format of the
+// state and uses the full name of the message as manifest.
+type ProtoSerializer struct {
+	resolver protoregistry.MessageTypeResolver
+}
+
+// enforce interface implementation
+var _ Serializer = (*ProtoSerializer)(nil)
+
+// NewProtoSerializer creates a ProtoSerializer resolving manifests with the given resolver,
+// for instance a protoregistry.Types built from a descriptor set. protoregistry.GlobalTypes
+// is used when resolver is nil.
+func NewProtoSerializer(resolver protoregistry.MessageTypeResolver) *ProtoSerializer {
+	if resolver == nil {
+		resolver = protoregistry.GlobalTypes
+	}
+	return &ProtoSerializer{resolver: resolver}
+}
+
+// Marshal serializes the state into its protobuf wire format
+func (s *ProtoSerializer) Marshal(state *anypb.Any) (string, []byte, error) {
+	payload, err := proto.Marshal(state)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(state.ProtoReflect().Descriptor().FullName()), payload, nil
+}
+
+// Unmarshal converts a byte array given its manifest into a valid proto message. Payloads
+// stored as a concrete message rather than an anypb.Any are wrapped into an anypb.Any.
+func (s *ProtoSerializer) Unmarshal(manifest string, payload []byte) (*anypb.Any, error) {
+	mt, err := s.resolver.FindMessageByName(protoreflect.FullName(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	pm := mt.New().Interface()
+	err = proto.Unmarshal(payload, pm)
+	if err != nil {
+		return nil, err
+	}
+
+	if cast, ok := pm.(*anypb.Any); ok {
+		return cast, nil
+	}
+
+	state, err := anypb.New(pm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack message=%s: %w", manifest, err)
+	}
+	return state, nil
+}
@@ -0,0 +1,170 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoSerializerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		message proto.Message
+	}{
+		{name: "string", message: wrapperspb.String("order-1")},
+		{name: "int64", message: wrapperspb.Int64(42)},
+		{name: "empty", message: wrapperspb.Bool(false)},
+	}
+
+	serializer := NewProtoSerializer(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, err := anypb.New(test.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			manifest, payload, err := serializer.Marshal(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest != anyManifest {
+				t.Fatalf("manifest = %q, want %q", manifest, anyManifest)
+			}
+
+			got, err := serializer.Unmarshal(manifest, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, state) {
+				t.Fatalf("Unmarshal = %v, want %v", got, state)
+			}
+		})
+	}
+}
+
+func TestProtoSerializerDecodesStoredPayloads(t *testing.T) {
+	message := wrapperspb.String("order-1")
+	state, err := anypb.New(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedAsAny, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedAsConcrete, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		payload  []byte
+		wantErr  bool
+	}{
+		{name: "stored as any", manifest: anyManifest, payload: storedAsAny},
+		{name: "stored as concrete message", manifest: "google.protobuf.StringValue", payload: storedAsConcrete},
+		{name: "unknown manifest", manifest: "acme.Unknown", payload: storedAsConcrete, wantErr: true},
+		{name: "corrupt payload", manifest: anyManifest, payload: []byte{0xff, 0xff}, wantErr: true},
+	}
+
+	serializer := NewProtoSerializer(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := serializer.Unmarshal(test.manifest, test.payload)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, state) {
+				t.Fatalf("Unmarshal = %v, want %v", got, state)
+			}
+
+			unpacked, err := got.UnmarshalNew()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(unpacked, message) {
+				t.Fatalf("unpacked state = %v, want %v", unpacked, message)
+			}
+		})
+	}
+}
+
+func TestToDurableStateDecodesStoredPayloads(t *testing.T) {
+	message := wrapperspb.String("order-1")
+	state, err := anypb.New(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedAsAny, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedAsConcrete, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		item    *StateItem
+		wantErr error
+	}{
+		{
+			name: "stored as any",
+			item: &StateItem{PersistenceID: "order-1", VersionNumber: 3, StateManifest: anyManifest, StatePayload: storedAsAny},
+		},
+		{
+			name: "stored as concrete message",
+			item: &StateItem{PersistenceID: "order-1", VersionNumber: 3, StateManifest: "google.protobuf.StringValue", StatePayload: storedAsConcrete},
+		},
+		{
+			name:    "unknown manifest",
+			item:    &StateItem{PersistenceID: "order-1", VersionNumber: 3, StateManifest: "acme.Unknown", StatePayload: storedAsConcrete},
+			wantErr: ErrCorruptPayload,
+		},
+	}
+
+	store := NewStateStore()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := store.toDurableState(context.Background(), test.item, "", OperationGetLatestState)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("toDurableState error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertDurableState(t, got, &egopb.DurableState{PersistenceId: "order-1", VersionNumber: 3, ResultingState: state})
+		})
+	}
+}
+
+// assertDurableState fails the test when the durable states differ
+func assertDurableState(t testing.TB, got, want *egopb.DurableState) {
+	t.Helper()
+	if got.GetPersistenceId() != want.GetPersistenceId() ||
+		got.GetVersionNumber() != want.GetVersionNumber() ||
+		got.GetTimestamp() != want.GetTimestamp() ||
+		got.GetShard() != want.GetShard() ||
+		!proto.Equal(got.GetResultingState(), want.GetResultingState()) {
+		t.Fatalf("durable state = %+v, want %+v", got, want)
+	}
+}
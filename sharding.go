@@ -0,0 +1,227 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// writeShardSeparator separates the partition key of a hot persistence ID from its write shard
+const writeShardSeparator = "~"
+
+// writeSharding spreads the writes of hot persistence IDs over several partition keys.
+// Every version is written under a key derived from a hash of the version, and reads fetch
+// all the keys in parallel to keep the newest version.
+type writeSharding struct {
+	shards int
+	hot    func(persistenceID string) bool
+}
+
+// isHot reports whether the writes of the given persistence ID are sharded
+func (s *writeSharding) isHot(persistenceID string) bool {
+	return s != nil && s.hot(persistenceID)
+}
+
+// shardKey returns the partition key the given version of a hot persistence ID is written under
+func (s *writeSharding) shardKey(partitionKey string, version uint64) string {
+	var versionBytes [8]byte
+	binary.BigEndian.PutUint64(versionBytes[:], version)
+	hash := fnv32a(fnv32a(fnvOffset32, partitionKey), versionBytes[:])
+	return partitionKey + writeShardSeparator + strconv.Itoa(int(hash%uint32(s.shards)))
+}
+
+// FNV-1a parameters, see hash/fnv
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// fnv32a continues the FNV-1a hash of the data hashed so far. It matches hash/fnv without
+// allocating a hash.Hash32 on every write.
+func fnv32a[T ~string | ~[]byte](hash uint32, data T) uint32 {
+	for index := 0; index < len(data); index++ {
+		hash ^= uint32(data[index])
+		hash *= fnvPrime32
+	}
+	return hash
+}
+
+// keys returns every partition key a hot persistence ID may be stored under. The unsharded
+// key is included since write-behind, WriteStates and the states written before the
+// persistence ID became hot use it.
+func (s *writeSharding) keys(partitionKey string) []string {
+	keys := make([]string, 0, s.shards+1)
+	keys = append(keys, partitionKey)
+	for shard := 0; shard < s.shards; shard++ {
+		keys = append(keys, partitionKey+writeShardSeparator+strconv.Itoa(shard))
+	}
+	return keys
+}
+
+// splitPartitionKey returns the tenant prefix of a partition key and its persistence ID,
+// stripped from the write shard suffix of the items of a hot persistence ID
+func (d *DynamoDurableStore) splitPartitionKey(partitionKey string) (prefix, persistenceID string) {
+	persistenceID = partitionKey
+	if d.tenant != nil {
+		if tenantID, rest, ok := strings.Cut(partitionKey, tenantSeparator); ok {
+			prefix, persistenceID = tenantID+tenantSeparator, rest
+		}
+	}
+	return prefix, d.sharding.unshard(persistenceID)
+}
+
+// basePartitionKey strips the write shard suffix from the partition key of an item of a hot
+// persistence ID, keeping its tenant prefix
+func (d *DynamoDurableStore) basePartitionKey(partitionKey string) string {
+	prefix, persistenceID := d.splitPartitionKey(partitionKey)
+	return prefix + persistenceID
+}
+
+// latestVersions remembers the newest live version of the hot persistence IDs met by a scan,
+// keyed by their unsharded partition key, zero when the state is deleted
+type latestVersions struct {
+	mu       sync.Mutex
+	versions map[string]uint64
+}
+
+// newLatestVersions creates the newest versions of a scan
+func newLatestVersions() *latestVersions {
+	return &latestVersions{versions: make(map[string]uint64)}
+}
+
+// isLatestCopy reports whether the scanned item stored under the given partition key with the
+// given version is the newest live version of its persistence ID. Scans come across every item
+// stored under the write shard keys of a hot persistence ID: its newest version is fetched from
+// all the keys, once per scan, and the older items are skipped.
+func (d *DynamoDurableStore) isLatestCopy(ctx context.Context, latest *latestVersions, partitionKey string, version uint64) (bool, error) {
+	if d.sharding == nil {
+		return true, nil
+	}
+	prefix, persistenceID := d.splitPartitionKey(partitionKey)
+	if !d.sharding.isHot(persistenceID) {
+		return true, nil
+	}
+	base := prefix + persistenceID
+
+	latest.mu.Lock()
+	newest, ok := latest.versions[base]
+	latest.mu.Unlock()
+	if !ok {
+		item, err := d.getShardedItem(ctx, base)
+		if err != nil {
+			return false, err
+		}
+		if item != nil && item.DeletedAt == 0 {
+			newest = item.VersionNumber
+		}
+		latest.mu.Lock()
+		latest.versions[base] = newest
+		latest.mu.Unlock()
+	}
+	return newest != 0 && version == newest, nil
+}
+
+// unshard strips the write shard suffix from the persistence ID of an item of a hot persistence ID
+func (s *writeSharding) unshard(persistenceID string) string {
+	if s == nil {
+		return persistenceID
+	}
+	base, suffix, ok := cutLast(persistenceID, writeShardSeparator)
+	if !ok || !s.hot(base) {
+		return persistenceID
+	}
+	if shard, err := strconv.Atoi(suffix); err != nil || shard < 0 || shard >= s.shards {
+		return persistenceID
+	}
+	return base
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if index := strings.LastIndex(s, sep); index >= 0 {
+		return s[:index], s[index+len(sep):], true
+	}
+	return s, "", false
+}
+
+// stateAttributes converts the item into the attributes written to the states table, under
+// its write shard key when the persistence ID is hot
+func (d *DynamoDurableStore) stateAttributes(item *StateItem) map[string]types.AttributeValue {
+	attributes := d.schema.marshal(item)
+	if item.shardKey != "" {
+		attributes[d.schema.PartitionKey] = &types.AttributeValueMemberS{Value: item.shardKey}
+	}
+	return attributes
+}
+
+// stateKey returns the partition key the item is written under
+func stateKey(item *StateItem) string {
+	if item.shardKey != "" {
+		return item.shardKey
+	}
+	return item.PersistenceID
+}
+
+// getShardedItem fetches in parallel every key of a hot persistence ID and returns the newest
+// version, nil when the state does not exist
+func (d *DynamoDurableStore) getShardedItem(ctx context.Context, partitionKey string) (*StateItem, error) {
+	keys := d.sharding.keys(partitionKey)
+	items := make([]*StateItem, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for index, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items[index], errs[index] = d.getStateItem(ctx, key)
+		}()
+	}
+	wg.Wait()
+
+	var newest *StateItem
+	for index, item := range items {
+		if errs[index] != nil {
+			return nil, errs[index]
+		}
+		if item != nil && (newest == nil || item.VersionNumber > newest.VersionNumber) {
+			newest = item
+		}
+	}
+	if newest != nil {
+		newest.PersistenceID = partitionKey
+	}
+	return newest, nil
+}
+
+// getStateItem fetches the item stored under the given partition key, nil when it does not exist
+func (d *DynamoDurableStore) getStateItem(ctx context.Context, partitionKey string) (*StateItem, error) {
+	var resp *dynamodb.GetItemOutput
+	err := d.execute(ctx, OperationGetLatestState, func(ctx context.Context) (err error) {
+		resp, err = d.hedgedGetItem(ctx, &dynamodb.GetItemInput{
+			TableName:              aws.String(d.schema.TableName),
+			Key:                    d.schema.key(partitionKey),
+			ConsistentRead:         aws.Bool(callOptionsFrom(ctx).consistentRead),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationGetLatestState, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest state from the dynamodb: %w", err)
+	}
+
+	if resp.Item == nil {
+		return nil, nil
+	}
+	return d.schema.unmarshal(resp.Item)
+}
@@ -0,0 +1,245 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// SnapshotPolicy configures the snapshots of the event-sourced entities
+type SnapshotPolicy struct {
+	// Table is the name of the snapshots table, keyed by PersistenceID and VersionNumber,
+	// the latter holding the sequence number of the snapshot
+	Table string
+	// Every is the number of events between two snapshots of an entity, see ShouldSnapshot
+	Every uint64
+	// KeepLast is the number of most recent snapshots kept per entity, zero keeps all of them
+	KeepLast int
+}
+
+// Snapshot is the state of an event-sourced entity after the event of the given sequence number
+type Snapshot struct {
+	PersistenceID  string
+	SequenceNumber uint64
+	State          *anypb.Any
+	Timestamp      int64
+	Shard          uint64
+}
+
+// SnapshotOf returns the snapshot of the resulting state of the given event
+func SnapshotOf(event *egopb.Event) *Snapshot {
+	return &Snapshot{
+		PersistenceID:  event.GetPersistenceId(),
+		SequenceNumber: event.GetSequenceNumber(),
+		State:          event.GetResultingState(),
+		Timestamp:      event.GetTimestamp(),
+		Shard:          event.GetShard(),
+	}
+}
+
+// ShouldSnapshot reports whether the event of the given sequence number is due for a snapshot
+// according to the snapshot policy
+func (d *DynamoDurableStore) ShouldSnapshot(sequenceNumber uint64) bool {
+	return d.snapshots != nil && d.snapshots.Every > 0 && sequenceNumber > 0 && sequenceNumber%d.snapshots.Every == 0
+}
+
+// SaveSnapshot persists the snapshot, encoded like the durable states: the configured
+// serializer, compression, encryption, checksum and S3 overflow apply. The older snapshots of
+// the entity falling out of the retention are then pruned; a failure to prune is only logged.
+func (d *DynamoDurableStore) SaveSnapshot(ctx context.Context, snapshot *Snapshot) error {
+	if d.snapshots == nil {
+		return errors.New("snapshots are not enabled")
+	}
+	item, err := d.newStateItem(ctx, &egopb.DurableState{
+		PersistenceId:  snapshot.PersistenceID,
+		VersionNumber:  snapshot.SequenceNumber,
+		ResultingState: snapshot.State,
+		Timestamp:      snapshot.Timestamp,
+		Shard:          snapshot.Shard,
+	}, OperationSaveSnapshot)
+	if err != nil {
+		return err
+	}
+
+	err = d.execute(ctx, OperationSaveSnapshot, func(ctx context.Context) error {
+		resp, err := d.client.Load().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:              aws.String(d.snapshots.Table),
+			Item:                   d.schema.marshal(item),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationSaveSnapshot, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the snapshot=%d of persistenceID=%s: %w", snapshot.SequenceNumber, snapshot.PersistenceID, err)
+	}
+
+	if _, err := d.pruneSnapshots(ctx, item.PersistenceID); err != nil {
+		d.logger.Warn("failed to prune the snapshots", "persistenceID", snapshot.PersistenceID, "error", err)
+	}
+	return nil
+}
+
+// LatestSnapshot returns the most recent snapshot of the entity, nil when it has none.
+// Recovering an entity replays its events from the sequence number following the snapshot.
+func (d *DynamoDurableStore) LatestSnapshot(ctx context.Context, persistenceID string) (*Snapshot, error) {
+	if d.snapshots == nil {
+		return nil, errors.New("snapshots are not enabled")
+	}
+
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dynamodb.QueryOutput
+	err = d.execute(ctx, OperationLatestSnapshot, func(ctx context.Context) (err error) {
+		resp, err = d.client.Load().Query(ctx, &dynamodb.QueryInput{
+			TableName:                aws.String(d.snapshots.Table),
+			KeyConditionExpression:   aws.String("#PersistenceID = :id"),
+			ExpressionAttributeNames: d.schema.names("PersistenceID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id": &types.AttributeValueMemberS{Value: prefix + persistenceID},
+			},
+			ScanIndexForward:       aws.Bool(false),
+			Limit:                  aws.Int32(1),
+			ConsistentRead:         aws.Bool(callOptionsFrom(ctx).consistentRead),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		})
+		if err == nil {
+			d.observeConsumedCapacity(ctx, OperationLatestSnapshot, resp.ConsumedCapacity)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest snapshot of persistenceID=%s: %w", persistenceID, err)
+	}
+
+	if len(resp.Items) == 0 {
+		return nil, nil
+	}
+	item, err := d.schema.unmarshal(resp.Items[0])
+	if err != nil {
+		return nil, err
+	}
+	state, err := d.toDurableState(ctx, item, prefix, OperationLatestSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		PersistenceID:  state.GetPersistenceId(),
+		SequenceNumber: state.GetVersionNumber(),
+		State:          state.GetResultingState(),
+		Timestamp:      state.GetTimestamp(),
+		Shard:          state.GetShard(),
+	}, nil
+}
+
+// PruneSnapshots deletes the snapshots of the entity falling out of the retention and returns
+// how many were deleted
+func (d *DynamoDurableStore) PruneSnapshots(ctx context.Context, persistenceID string) (int, error) {
+	if d.snapshots == nil {
+		return 0, errors.New("snapshots are not enabled")
+	}
+
+	partitionKey, err := d.partitionKey(ctx, persistenceID)
+	if err != nil {
+		return 0, err
+	}
+	return d.pruneSnapshots(ctx, partitionKey)
+}
+
+// pruneSnapshots deletes the snapshots of the given partition key older than the KeepLast most
+// recent ones and returns how many were deleted
+func (d *DynamoDurableStore) pruneSnapshots(ctx context.Context, partitionKey string) (int, error) {
+	if d.snapshots.KeepLast <= 0 {
+		return 0, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(d.snapshots.Table),
+		KeyConditionExpression:   aws.String("#PersistenceID = :id"),
+		ProjectionExpression:     aws.String("#PersistenceID, #VersionNumber"),
+		ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: partitionKey},
+		},
+		ScanIndexForward:       aws.Bool(false),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+
+	var (
+		kept   int
+		pruned int
+	)
+	for {
+		var resp *dynamodb.QueryOutput
+		err := d.execute(ctx, OperationPruneSnapshots, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Query(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationPruneSnapshots, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("failed to query the snapshots of persistenceID=%s: %w", partitionKey, err)
+		}
+
+		// the most recent snapshots come first
+		keys := resp.Items
+		skip := min(d.snapshots.KeepLast-kept, len(keys))
+		kept += skip
+		keys = keys[skip:]
+
+		for start := 0; start < len(keys); start += maxBatchWriteItems {
+			end := min(start+maxBatchWriteItems, len(keys))
+			requests := make([]types.WriteRequest, 0, end-start)
+			for _, key := range keys[start:end] {
+				requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+			}
+			if err := d.batchWriteRequests(ctx, OperationPruneSnapshots, d.snapshots.Table, requests); err != nil {
+				return pruned, fmt.Errorf("failed to prune the snapshots of persistenceID=%s: %w", partitionKey, err)
+			}
+			pruned += len(requests)
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return pruned, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// createSnapshotTable creates the snapshots table keyed by PersistenceID and VersionNumber
+func (d *DynamoDurableStore) createSnapshotTable(ctx context.Context) error {
+	_, err := d.client.Load().CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(d.snapshots.Table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(d.schema.PartitionKey), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(d.schema.attr("VersionNumber")), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(d.schema.PartitionKey), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(d.schema.attr("VersionNumber")), KeyType: types.KeyTypeRange},
+		},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create the snapshots table: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(d.client.Load())
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.snapshots.Table)}, tableActiveTimeout); err != nil {
+		return fmt.Errorf("failed to wait for the snapshots table to be active: %w", err)
+	}
+	return nil
+}
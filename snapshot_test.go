@@ -0,0 +1,157 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// snapshotsTable is the snapshots table of the tests
+const snapshotsTable = "states_store_snapshots"
+
+// newSnapshotStore creates a store connected to a fake endpoint serving the states and
+// snapshots tables
+func newSnapshotStore(t *testing.T, policy SnapshotPolicy, opts ...Option) (*DynamoDurableStore, *fakeDynamoDB) {
+	t.Helper()
+	policy.Table = snapshotsTable
+	store, fake := newFakeStore(t, append(opts, WithSnapshots(policy))...)
+	fake.createTable(snapshotsTable, DefaultSchema.PartitionKey, "VersionNumber")
+	return store, fake
+}
+
+// testSnapshot returns the snapshot of the given persistence ID and sequence number
+func testSnapshot(t *testing.T, persistenceID string, sequenceNumber uint64) *Snapshot {
+	t.Helper()
+	state := testState(t, persistenceID, sequenceNumber)
+	return &Snapshot{
+		PersistenceID:  persistenceID,
+		SequenceNumber: sequenceNumber,
+		State:          state.GetResultingState(),
+		Timestamp:      state.GetTimestamp(),
+	}
+}
+
+func TestShouldSnapshot(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         *SnapshotPolicy
+		sequenceNumber uint64
+		want           bool
+	}{
+		{name: "snapshots disabled", sequenceNumber: 10},
+		{name: "no interval", policy: &SnapshotPolicy{Table: snapshotsTable, Every: 0}, sequenceNumber: 10},
+		{name: "first event not recorded", policy: &SnapshotPolicy{Table: snapshotsTable, Every: 5}, sequenceNumber: 0},
+		{name: "due", policy: &SnapshotPolicy{Table: snapshotsTable, Every: 5}, sequenceNumber: 10, want: true},
+		{name: "not due", policy: &SnapshotPolicy{Table: snapshotsTable, Every: 5}, sequenceNumber: 11},
+		{name: "every event", policy: &SnapshotPolicy{Table: snapshotsTable, Every: 1}, sequenceNumber: 1, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var opts []Option
+			if test.policy != nil {
+				opts = append(opts, WithSnapshots(*test.policy))
+			}
+			store := NewStateStore(opts...)
+			if got := store.ShouldSnapshot(test.sequenceNumber); got != test.want {
+				t.Fatalf("ShouldSnapshot(%d) = %t, want %t", test.sequenceNumber, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSaveSnapshotKeepsLastSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newSnapshotStore(t, SnapshotPolicy{Every: 1, KeepLast: 2})
+
+	for sequenceNumber := uint64(1); sequenceNumber <= 5; sequenceNumber++ {
+		if err := store.SaveSnapshot(ctx, testSnapshot(t, "order-1", sequenceNumber)); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveSnapshot(ctx, testSnapshot(t, "order-2", sequenceNumber)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := fake.itemCount(snapshotsTable); got != 4 {
+		t.Fatalf("snapshots count = %d, want 4", got)
+	}
+	for _, persistenceID := range []string{"order-1", "order-2"} {
+		var sequenceNumbers []string
+		for _, item := range fake.items(snapshotsTable) {
+			if item[DefaultSchema.PartitionKey]["S"] == persistenceID {
+				sequenceNumbers = append(sequenceNumbers, item["VersionNumber"]["N"].(string))
+			}
+		}
+		if len(sequenceNumbers) != 2 || sequenceNumbers[0] != "4" || sequenceNumbers[1] != "5" {
+			t.Fatalf("snapshots of %s = %v, want [4 5]", persistenceID, sequenceNumbers)
+		}
+	}
+
+	latest, err := store.LatestSnapshot(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testSnapshot(t, "order-1", 5)
+	assertDurableState(t,
+		&egopb.DurableState{PersistenceId: latest.PersistenceID, VersionNumber: latest.SequenceNumber, ResultingState: latest.State, Timestamp: latest.Timestamp},
+		&egopb.DurableState{PersistenceId: want.PersistenceID, VersionNumber: want.SequenceNumber, ResultingState: want.State, Timestamp: want.Timestamp})
+
+	pruned, err := store.PruneSnapshots(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 0 {
+		t.Fatalf("PruneSnapshots = %d, want 0", pruned)
+	}
+}
+
+func TestSaveSnapshotKeepsAllSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newSnapshotStore(t, SnapshotPolicy{Every: 1})
+
+	for sequenceNumber := uint64(1); sequenceNumber <= 3; sequenceNumber++ {
+		if err := store.SaveSnapshot(ctx, testSnapshot(t, "order-1", sequenceNumber)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fake.itemCount(snapshotsTable); got != 3 {
+		t.Fatalf("snapshots count = %d, want 3", got)
+	}
+}
+
+func TestLatestSnapshotIsScopedToTheTenant(t *testing.T) {
+	type tenantKey struct{}
+	store, _ := newSnapshotStore(t, SnapshotPolicy{Every: 1}, WithTenantResolver(func(ctx context.Context) (string, error) {
+		tenantID, _ := ctx.Value(tenantKey{}).(string)
+		return tenantID, nil
+	}))
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	for sequenceNumber := uint64(1); sequenceNumber <= 2; sequenceNumber++ {
+		if err := store.SaveSnapshot(acme, testSnapshot(t, "order-1", sequenceNumber)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := store.LatestSnapshot(globex, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != nil {
+		t.Fatalf("LatestSnapshot of another tenant = %+v, want nil", latest)
+	}
+
+	latest, err = store.LatestSnapshot(acme, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil {
+		t.Fatal("LatestSnapshot = nil, want the snapshot of the tenant")
+	}
+	if latest.PersistenceID != "order-1" || latest.SequenceNumber != 2 {
+		t.Fatalf("LatestSnapshot = %s@%d, want order-1@2", latest.PersistenceID, latest.SequenceNumber)
+	}
+}
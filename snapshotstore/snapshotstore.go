@@ -0,0 +1,79 @@
+// Package snapshotstore provides an eventstore.EventsStore decorator persisting the snapshots of
+// the event-sourced entities in DynamoDB, so that entities with long histories recover from their
+// latest snapshot and the events following it instead of replaying their whole journal.
+package snapshotstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"github.com/tochemey/ego/v3/persistence"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+)
+
+// Store is an events journal taking the snapshots of the entities it writes
+type Store struct {
+	persistence.EventsStore
+	snapshots *dynamodb.DynamoDurableStore
+}
+
+// Wrap decorates the events journal with the snapshots of the store, which must be configured
+// with dynamodb.WithSnapshots. Connect and Disconnect do not manage the snapshots store.
+func Wrap(events persistence.EventsStore, snapshots *dynamodb.DynamoDurableStore) *Store {
+	return &Store{EventsStore: events, snapshots: snapshots}
+}
+
+// WriteEvents persists the events in the journal, then saves the resulting state of the events
+// due for a snapshot according to the snapshot policy. Snapshots only speed up the recoveries,
+// so the events are persisted even when saving a snapshot fails, and the error is returned.
+func (s *Store) WriteEvents(ctx context.Context, events []*egopb.Event) error {
+	if err := s.EventsStore.WriteEvents(ctx, events); err != nil {
+		return err
+	}
+
+	// snapshot only the last event due of every entity of the batch
+	due := make(map[string]*egopb.Event)
+	for _, event := range events {
+		if s.snapshots.ShouldSnapshot(event.GetSequenceNumber()) {
+			if latest, ok := due[event.GetPersistenceId()]; !ok || latest.GetSequenceNumber() < event.GetSequenceNumber() {
+				due[event.GetPersistenceId()] = event
+			}
+		}
+	}
+	for _, event := range due {
+		if err := s.snapshots.SaveSnapshot(ctx, dynamodb.SnapshotOf(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recover returns the latest snapshot of the entity, nil when it has none, and the events of the
+// journal following it, which are applied to the snapshot state to rebuild the entity
+func (s *Store) Recover(ctx context.Context, persistenceID string) (*dynamodb.Snapshot, []*egopb.Event, error) {
+	snapshot, err := s.snapshots.LatestSnapshot(ctx, persistenceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from := uint64(1)
+	if snapshot != nil {
+		from = snapshot.SequenceNumber + 1
+	}
+	latest, err := s.EventsStore.GetLatestEvent(ctx, persistenceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch the latest event of persistenceID=%s: %w", persistenceID, err)
+	}
+	if latest == nil || latest.GetSequenceNumber() < from {
+		return snapshot, nil, nil
+	}
+
+	events, err := s.EventsStore.ReplayEvents(ctx, persistenceID, from, latest.GetSequenceNumber(), math.MaxUint64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to replay the events of persistenceID=%s: %w", persistenceID, err)
+	}
+	return snapshot, events, nil
+}
@@ -0,0 +1,187 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// statsLargestItems is the number of largest items reported by Stats
+const statsLargestItems = 10
+
+// Stats describes the content of the states table
+type Stats struct {
+	TableName string
+	// ItemCount and SizeBytes are approximations of the whole table, tenants and tombstones
+	// included, refreshed by DynamoDB every six hours
+	ItemCount int64
+	SizeBytes int64
+	// ShardCounts is the number of live states of every shard
+	ShardCounts map[uint64]int64
+	// LargestItems are the largest live items, the largest first
+	LargestItems []ItemSize
+}
+
+// ItemSize is the size of the item of a durable state
+type ItemSize struct {
+	PersistenceID string
+	VersionNumber uint64
+	// SizeBytes is the size of the item as computed by DynamoDB, payloads offloaded to S3 excluded
+	SizeBytes int
+}
+
+// Stats counts the states of every shard and finds the largest items by scanning the ShardIndex
+// global secondary index, see EnsureTable, and reports the approximate table size. The scan reads
+// the whole table: it is meant for dashboards refreshed a few times a day. A hot persistence ID
+// counts once, with the item holding its newest version, see WithWriteSharding.
+func (d *DynamoDurableStore) Stats(ctx context.Context) (*Stats, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var table *dynamodb.DescribeTableOutput
+	err = d.execute(ctx, OperationStats, func(ctx context.Context) (err error) {
+		table, err = d.client.Load().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(d.schema.TableName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the table in the dynamodb: %w", err)
+	}
+
+	stats := &Stats{
+		TableName:   d.schema.TableName,
+		ItemCount:   aws.ToInt64(table.Table.ItemCount),
+		SizeBytes:   aws.ToInt64(table.Table.TableSizeBytes),
+		ShardCounts: make(map[uint64]int64),
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(d.schema.TableName),
+		IndexName:              aws.String(ShardIndex),
+		ReturnConsumedCapacity: d.returnConsumedCapacity(),
+	}
+	d.schema.applyTenantFilter(input, prefix)
+
+	latest := newLatestVersions()
+	for {
+		var resp *dynamodb.ScanOutput
+		err := d.execute(ctx, OperationStats, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Scan(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationStats, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the shard index of the dynamodb: %w", err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return nil, err
+			}
+			if item.DeletedAt > 0 {
+				continue
+			}
+			latestCopy, err := d.isLatestCopy(ctx, latest, item.PersistenceID, item.VersionNumber)
+			if err != nil {
+				return nil, err
+			}
+			if !latestCopy {
+				continue
+			}
+			persistenceID, err := persistenceIDOf(prefix, item.PersistenceID)
+			if err != nil {
+				return nil, err
+			}
+
+			stats.ShardCounts[item.ShardNumber]++
+			stats.LargestItems = insertLargest(stats.LargestItems, ItemSize{
+				PersistenceID: d.sharding.unshard(persistenceID),
+				VersionNumber: item.VersionNumber,
+				SizeBytes:     itemSize(attributes),
+			})
+		}
+
+		if resp.LastEvaluatedKey == nil {
+			return stats, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// insertLargest inserts the item into the given largest items, sorted by decreasing size,
+// keeping at most statsLargestItems of them
+func insertLargest(largest []ItemSize, item ItemSize) []ItemSize {
+	i := sort.Search(len(largest), func(i int) bool { return largest[i].SizeBytes < item.SizeBytes })
+	if i >= statsLargestItems {
+		return largest
+	}
+	if len(largest) < statsLargestItems {
+		largest = append(largest, ItemSize{})
+	}
+	copy(largest[i+1:], largest[i:])
+	largest[i] = item
+	return largest
+}
+
+// itemSize computes the size of an item the way DynamoDB does: the lengths of the attribute
+// names plus the sizes of their values
+func itemSize(attributes map[string]types.AttributeValue) int {
+	size := 0
+	for name, value := range attributes {
+		size += len(name) + attributeSize(value)
+	}
+	return size
+}
+
+// attributeSize computes the size of an attribute value
+func attributeSize(value types.AttributeValue) int {
+	switch value := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(value.Value)
+	case *types.AttributeValueMemberB:
+		return len(value.Value)
+	case *types.AttributeValueMemberN:
+		// numbers are stored in base 100 with up to 38 significant digits
+		return (len(value.Value)+1)/2 + 1
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(value.Value)
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, element := range value.Value {
+			size += 1 + attributeSize(element)
+		}
+		return size
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, element := range value.Value {
+			size += len(element)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, element := range value.Value {
+			size += len(element)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, element := range value.Value {
+			size += (len(element)+1)/2 + 1
+		}
+		return size
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,278 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	streamstypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+const (
+	// streamPollInterval is the delay between two GetRecords calls on a shard without new records
+	streamPollInterval = time.Second
+	// streamShardRefreshInterval is the delay between two discoveries of new stream shards
+	streamShardRefreshInterval = time.Minute
+)
+
+// StateChange notifies a durable state modification captured by the table stream
+type StateChange struct {
+	PersistenceID string
+	// OldVersion is the version before the change, zero when the state has been created
+	OldVersion uint64
+	// NewVersion is the version after the change, zero when the state has been removed
+	NewVersion uint64
+	// Removed is set when the state has been deleted or soft-deleted
+	Removed bool
+}
+
+// ChangeHandler processes a state change. Returning an error stops the subscription.
+type ChangeHandler func(ctx context.Context, change *StateChange) error
+
+// Subscribe consumes the DynamoDB Stream of the states table and calls the handler for
+// every state change happening after the subscription started. The stream must be enabled
+// with the NEW_AND_OLD_IMAGES view type. Changes of a given persistence ID are delivered in
+// order: after a shard split, the child shards are only read once their parent has been read to
+// its end. Changes of different persistence IDs may be delivered concurrently.
+// Subscribe blocks until the context is done or the handler fails.
+func (d *DynamoDurableStore) Subscribe(ctx context.Context, handler ChangeHandler) error {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return err
+	}
+	return d.subscribe(ctx, prefix, handler)
+}
+
+// subscribe consumes the table stream and calls the handler for the changes of the states
+// whose partition key starts with the given prefix. The persistence IDs of the changes are
+// stripped from the prefix.
+func (d *DynamoDurableStore) subscribe(ctx context.Context, prefix string, handler ChangeHandler) error {
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	table, err := d.client.Load().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(d.schema.TableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe the table: %w", err)
+	}
+	if table.Table.LatestStreamArn == nil {
+		return fmt.Errorf("table=%s has no stream enabled", d.schema.TableName)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	subscriber := &streamSubscriber{
+		client:    d.streams.Load(),
+		streamARN: aws.ToString(table.Table.LatestStreamArn),
+		handler:   handler,
+		schema:    d.schema,
+		baseKey:   d.basePartitionKey,
+		prefix:    prefix,
+		started:   make(map[string]bool),
+		finished:  make(map[string]bool),
+		closed:    make(chan string),
+		errs:      make(chan error, 1),
+	}
+
+	err = subscriber.run(ctx)
+	cancel()
+	subscriber.wg.Wait()
+	return err
+}
+
+// streamSubscriber consumes every shard of a DynamoDB stream
+type streamSubscriber struct {
+	client    *dynamodbstreams.Client
+	streamARN string
+	handler   ChangeHandler
+	schema    Schema
+	baseKey   func(partitionKey string) string // strips the write shard suffix of a partition key
+	prefix    string
+	started   map[string]bool
+	finished  map[string]bool // shards read to their end, whose children can be read
+	closed    chan string     // receives the shards read to their end by their consumer
+	errs      chan error
+	wg        sync.WaitGroup
+}
+
+// run discovers the stream shards until the context is done or a shard consumer fails
+func (s *streamSubscriber) run(ctx context.Context) error {
+	// shards existing before the subscription are read from their tip, new shards from their start
+	iteratorType := streamstypes.ShardIteratorTypeLatest
+	ticker := time.NewTicker(streamShardRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		shards, err := s.listShards(ctx)
+		if err != nil {
+			return err
+		}
+		s.startShards(ctx, shards, iteratorType)
+		iteratorType = streamstypes.ShardIteratorTypeTrimHorizon
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-s.errs:
+			return err
+		case shardID := <-s.closed:
+			// the children of the shard are discovered and started right away
+			s.finished[shardID] = true
+		case <-ticker.C:
+		}
+	}
+}
+
+// startShards starts consuming the shards not started yet whose parent, if still in the
+// stream, has been read to its end
+func (s *streamSubscriber) startShards(ctx context.Context, shards []streamstypes.Shard, iteratorType streamstypes.ShardIteratorType) {
+	listed := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		shardID := aws.ToString(shard.ShardId)
+		listed[shardID] = true
+		// a closed shard discovered at subscription time holds no future change
+		if iteratorType == streamstypes.ShardIteratorTypeLatest && shard.SequenceNumberRange != nil &&
+			shard.SequenceNumberRange.EndingSequenceNumber != nil {
+			s.started[shardID] = true
+			s.finished[shardID] = true
+		}
+	}
+
+	for _, shard := range shards {
+		shardID := aws.ToString(shard.ShardId)
+		if s.started[shardID] {
+			continue
+		}
+		if parentID := aws.ToString(shard.ParentShardId); parentID != "" && listed[parentID] && !s.finished[parentID] {
+			continue
+		}
+		s.started[shardID] = true
+		s.wg.Add(1)
+		go s.consume(ctx, shardID, iteratorType)
+	}
+}
+
+// listShards returns all the shards of the stream
+func (s *streamSubscriber) listShards(ctx context.Context) ([]streamstypes.Shard, error) {
+	var (
+		shards     []streamstypes.Shard
+		startShard *string
+	)
+	for {
+		resp, err := s.client.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(s.streamARN),
+			ExclusiveStartShardId: startShard,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the stream: %w", err)
+		}
+		shards = append(shards, resp.StreamDescription.Shards...)
+		startShard = resp.StreamDescription.LastEvaluatedShardId
+		if startShard == nil {
+			return shards, nil
+		}
+	}
+}
+
+// consume reads the records of a shard until it is closed or the context is done
+func (s *streamSubscriber) consume(ctx context.Context, shardID string, iteratorType streamstypes.ShardIteratorType) {
+	defer s.wg.Done()
+	err := s.consumeShard(ctx, shardID, iteratorType)
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		select {
+		case s.errs <- err:
+		default:
+		}
+	default:
+		select {
+		case s.closed <- shardID:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (s *streamSubscriber) consumeShard(ctx context.Context, shardID string, iteratorType streamstypes.ShardIteratorType) error {
+	iterator, err := s.client.GetShardIterator(ctx, &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(s.streamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: iteratorType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get the iterator of shard=%s: %w", shardID, err)
+	}
+
+	next := iterator.ShardIterator
+	for next != nil {
+		resp, err := s.client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: next,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get the records of shard=%s: %w", shardID, err)
+		}
+
+		for _, record := range resp.Records {
+			if change := s.toStateChange(record); change != nil {
+				if err := s.handler(ctx, change); err != nil {
+					return err
+				}
+			}
+		}
+
+		next = resp.NextShardIterator
+		if len(resp.Records) == 0 && next != nil {
+			if err := sleep(ctx, streamPollInterval); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toStateChange converts a stream record into a state change. Records of other tenants
+// than the one of the subscription are skipped.
+func (s *streamSubscriber) toStateChange(record streamstypes.Record) *StateChange {
+	if record.Dynamodb == nil {
+		return nil
+	}
+
+	// the write shard suffix follows the persistence ID, so it is stripped before the tenant prefix
+	persistenceID, err := persistenceIDOf(s.prefix, s.baseKey(parseStreamString(record.Dynamodb.Keys[s.schema.PartitionKey])))
+	if err != nil {
+		return nil
+	}
+
+	change := &StateChange{
+		PersistenceID: persistenceID,
+		OldVersion:    parseStreamUint64(record.Dynamodb.OldImage[s.schema.attr("VersionNumber")]),
+		NewVersion:    parseStreamUint64(record.Dynamodb.NewImage[s.schema.attr("VersionNumber")]),
+		Removed:       record.EventName == streamstypes.OperationTypeRemove || record.Dynamodb.NewImage[s.schema.attr("DeletedAt")] != nil,
+	}
+	if change.PersistenceID == "" {
+		return nil
+	}
+	return change
+}
+
+func parseStreamString(element streamstypes.AttributeValue) string {
+	if s, ok := element.(*streamstypes.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func parseStreamUint64(element streamstypes.AttributeValue) uint64 {
+	if n, ok := element.(*streamstypes.AttributeValueMemberN); ok {
+		value, _ := strconv.ParseUint(n.Value, 10, 64)
+		return value
+	}
+	return 0
+}
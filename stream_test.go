@@ -0,0 +1,100 @@
+package dynamodb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	streamstypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+func TestToStateChange(t *testing.T) {
+	hot := func(persistenceID string) bool { return strings.HasPrefix(persistenceID, "hot-") }
+	tests := []struct {
+		name         string
+		options      []Option
+		prefix       string
+		partitionKey string
+		want         string
+	}{
+		{name: "state", partitionKey: "order-1", want: "order-1"},
+		{name: "hot state shard", options: []Option{WithWriteSharding(4, hot)}, partitionKey: "hot-1~2", want: "hot-1"},
+		{name: "shard suffix of a cold state", options: []Option{WithWriteSharding(4, hot)}, partitionKey: "order-1~2", want: "order-1~2"},
+		{
+			name:         "tenant hot state shard for every tenant",
+			options:      []Option{WithTenant("acme"), WithWriteSharding(4, hot)},
+			partitionKey: "acme#hot-1~2",
+			want:         "acme#hot-1",
+		},
+		{
+			name:         "tenant hot state shard for its tenant",
+			options:      []Option{WithTenant("acme"), WithWriteSharding(4, hot)},
+			prefix:       "acme#",
+			partitionKey: "acme#hot-1~2",
+			want:         "hot-1",
+		},
+		{
+			name:         "tenant hot state shard for another tenant",
+			options:      []Option{WithTenant("acme"), WithWriteSharding(4, hot)},
+			prefix:       "acme#",
+			partitionKey: "globex#hot-1~2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewStateStore(test.options...)
+			subscriber := &streamSubscriber{schema: store.schema, baseKey: store.basePartitionKey, prefix: test.prefix}
+			change := subscriber.toStateChange(streamstypes.Record{
+				EventName: streamstypes.OperationTypeModify,
+				Dynamodb: &streamstypes.StreamRecord{
+					Keys:     map[string]streamstypes.AttributeValue{"PersistenceID": &streamstypes.AttributeValueMemberS{Value: test.partitionKey}},
+					NewImage: map[string]streamstypes.AttributeValue{"VersionNumber": &streamstypes.AttributeValueMemberN{Value: "3"}},
+				},
+			})
+
+			if test.want == "" {
+				if change != nil {
+					t.Fatalf("toStateChange = %+v, want nil", change)
+				}
+				return
+			}
+			if change == nil || change.PersistenceID != test.want || change.NewVersion != 3 {
+				t.Fatalf("toStateChange = %+v, want persistenceID=%s at version 3", change, test.want)
+			}
+		})
+	}
+}
+
+func TestStateChangeEvictsCachedTenantHotState(t *testing.T) {
+	hot := func(persistenceID string) bool { return strings.HasPrefix(persistenceID, "hot-") }
+	store, _ := newFakeStore(t, WithTenant("acme"), WithWriteSharding(4, hot), WithCache(10, 0))
+	ctx := context.Background()
+	if err := store.WriteState(ctx, testState(t, "hot-1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	partitionKey, err := store.partitionKey(ctx, "hot-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.cache.get(partitionKey); !ok {
+		t.Fatal("written state is not cached")
+	}
+
+	// another node writes the next version to a shard of the persistence ID
+	subscriber := &streamSubscriber{schema: store.schema, baseKey: store.basePartitionKey}
+	change := subscriber.toStateChange(streamstypes.Record{
+		EventName: streamstypes.OperationTypeModify,
+		Dynamodb: &streamstypes.StreamRecord{
+			Keys:     map[string]streamstypes.AttributeValue{"PersistenceID": &streamstypes.AttributeValueMemberS{Value: store.sharding.shardKey(partitionKey, 2)}},
+			NewImage: map[string]streamstypes.AttributeValue{"VersionNumber": &streamstypes.AttributeValueMemberN{Value: "2"}},
+		},
+	})
+	if change == nil {
+		t.Fatal("toStateChange = nil")
+	}
+	store.cache.invalidateOlder(change.PersistenceID, change.NewVersion)
+	if state, ok := store.cache.get(partitionKey); ok {
+		t.Fatalf("stale state %+v is still cached", state)
+	}
+}
@@ -0,0 +1,226 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// ShardIndex is the global secondary index of the states table keyed by ShardNumber
+	ShardIndex = "ShardIndex"
+	// UpdatedIndex is the global secondary index of the states table keyed by UpdatedBucket and UpdatedAt
+	UpdatedIndex = "UpdatedIndex"
+
+	// tableActiveTimeout is the maximum time EnsureTable waits for the table to become active
+	tableActiveTimeout = 5 * time.Minute
+	// indexActiveTimeout is the maximum time EnsureTable waits for an index added to an existing
+	// table to become active, the index being backfilled from the items of the table
+	indexActiveTimeout = 30 * time.Minute
+	// indexPollInterval is the time between two checks of the status of an index being created
+	indexPollInterval = 5 * time.Second
+)
+
+// EnsureTable creates the states table with its secondary indexes when it does not exist,
+// and adds the missing secondary indexes to an existing table, waiting for each index to be
+// backfilled, which takes minutes on a large table. The table uses on-demand
+// capacity, unless WithAutoScaling is set: the table and its indexes then use provisioned
+// capacity scaled by Application Auto Scaling. EnsureTable waits until the table is active.
+// The history, audit and snapshots tables are created as well when enabled.
+func (d *DynamoDurableStore) EnsureTable(ctx context.Context) error {
+	if err := d.ensureConnected(ctx); err != nil {
+		return err
+	}
+	if d.readOnly {
+		return ErrReadOnly
+	}
+
+	resp, err := d.client.Load().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(d.schema.TableName),
+	})
+
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		if err := d.createTable(ctx); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("failed to describe the table: %w", err)
+	default:
+		if err := d.createMissingIndexes(ctx, resp.Table); err != nil {
+			return err
+		}
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(d.client.Load())
+	described, err := waiter.WaitForOutput(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.schema.TableName)}, tableActiveTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to wait for the table to be active: %w", err)
+	}
+
+	if d.autoScaling != nil {
+		if err := d.configureAutoScaling(ctx, described.Table); err != nil {
+			return err
+		}
+	}
+
+	if d.history != nil {
+		if err := d.createHistoryTable(ctx); err != nil {
+			return err
+		}
+	}
+	if d.audit != nil {
+		if err := d.createAuditTable(ctx); err != nil {
+			return err
+		}
+	}
+	if d.snapshots != nil {
+		return d.createSnapshotTable(ctx)
+	}
+	return nil
+}
+
+// createTable creates the states table and its secondary indexes
+func (d *DynamoDurableStore) createTable(ctx context.Context) error {
+	_, err := d.client.Load().CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:              aws.String(d.schema.TableName),
+		BillingMode:            d.billingMode(),
+		ProvisionedThroughput:  d.provisionedThroughput(),
+		AttributeDefinitions:   d.attributeDefinitions(),
+		KeySchema:              d.schema.keySchema(),
+		GlobalSecondaryIndexes: d.globalSecondaryIndexes(),
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create the table: %w", err)
+	}
+	return nil
+}
+
+// createMissingIndexes adds the secondary indexes the table does not have yet. DynamoDB only
+// accepts one index creation per UpdateTable call and none while another index is being
+// created, so every index is waited for until it is active before the next one. The indexes follow the
+// billing mode of the existing table: no throughput on an on-demand table, the throughput
+// of the table on a provisioned one unless WithAutoScaling sets it.
+func (d *DynamoDurableStore) createMissingIndexes(ctx context.Context, table *types.TableDescription) error {
+	existing := indexStatuses(table)
+	for _, index := range d.globalSecondaryIndexes() {
+		if _, ok := existing[aws.ToString(index.IndexName)]; ok {
+			continue
+		}
+
+		throughput := index.ProvisionedThroughput
+		switch {
+		case tableBillingMode(table) == types.BillingModePayPerRequest:
+			throughput = nil
+		case throughput == nil && table.ProvisionedThroughput != nil:
+			throughput = &types.ProvisionedThroughput{
+				ReadCapacityUnits:  table.ProvisionedThroughput.ReadCapacityUnits,
+				WriteCapacityUnits: table.ProvisionedThroughput.WriteCapacityUnits,
+			}
+		}
+		_, err := d.client.Load().UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(d.schema.TableName),
+			AttributeDefinitions: d.attributeDefinitions(),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{
+					Create: &types.CreateGlobalSecondaryIndexAction{
+						IndexName:             index.IndexName,
+						KeySchema:             index.KeySchema,
+						Projection:            index.Projection,
+						ProvisionedThroughput: throughput,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create the index=%s: %w", aws.ToString(index.IndexName), err)
+		}
+		if err := d.waitForIndex(ctx, aws.ToString(index.IndexName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForIndex waits until the table and the given global secondary index are active
+func (d *DynamoDurableStore) waitForIndex(ctx context.Context, indexName string) error {
+	ctx, cancel := context.WithTimeout(ctx, indexActiveTimeout)
+	defer cancel()
+
+	waiter := dynamodb.NewTableExistsWaiter(d.client.Load())
+	for {
+		resp, err := waiter.WaitForOutput(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.schema.TableName)}, indexActiveTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to wait for the table to be active: %w", err)
+		}
+		if indexStatuses(resp.Table)[indexName] == types.IndexStatusActive {
+			return nil
+		}
+		if err := sleep(ctx, indexPollInterval); err != nil {
+			return fmt.Errorf("failed to wait for the index=%s to be active: %w", indexName, err)
+		}
+	}
+}
+
+// tableBillingMode returns the billing mode of an existing table. DynamoDB leaves out the
+// summary of tables created with provisioned capacity and never switched.
+func tableBillingMode(table *types.TableDescription) types.BillingMode {
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode != "" {
+		return table.BillingModeSummary.BillingMode
+	}
+	return types.BillingModeProvisioned
+}
+
+// indexStatuses returns the status of the global secondary indexes of an existing table by name
+func indexStatuses(table *types.TableDescription) map[string]types.IndexStatus {
+	statuses := make(map[string]types.IndexStatus, len(table.GlobalSecondaryIndexes))
+	for _, index := range table.GlobalSecondaryIndexes {
+		statuses[aws.ToString(index.IndexName)] = index.IndexStatus
+	}
+	return statuses
+}
+
+// attributeDefinitions returns the key attributes of the table and its indexes
+func (d *DynamoDurableStore) attributeDefinitions() []types.AttributeDefinition {
+	definitions := []types.AttributeDefinition{
+		{AttributeName: aws.String(d.schema.PartitionKey), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(d.schema.attr("ShardNumber")), AttributeType: types.ScalarAttributeTypeN},
+		{AttributeName: aws.String(d.schema.attr("UpdatedBucket")), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(d.schema.attr("UpdatedAt")), AttributeType: types.ScalarAttributeTypeN},
+	}
+	if d.schema.SortKey != "" {
+		definitions = append(definitions, types.AttributeDefinition{AttributeName: aws.String(d.schema.SortKey), AttributeType: types.ScalarAttributeTypeS})
+	}
+	return definitions
+}
+
+// globalSecondaryIndexes returns the global secondary indexes of the table
+func (d *DynamoDurableStore) globalSecondaryIndexes() []types.GlobalSecondaryIndex {
+	return []types.GlobalSecondaryIndex{
+		{
+			IndexName: aws.String(ShardIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String(d.schema.attr("ShardNumber")), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String(d.schema.PartitionKey), KeyType: types.KeyTypeRange},
+			},
+			Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			ProvisionedThroughput: d.provisionedThroughput(),
+		},
+		{
+			IndexName: aws.String(UpdatedIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String(d.schema.attr("UpdatedBucket")), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String(d.schema.attr("UpdatedAt")), KeyType: types.KeyTypeRange},
+			},
+			Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			ProvisionedThroughput: d.provisionedThroughput(),
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// tenantSeparator separates the tenant ID from the persistence ID in the partition key
+const tenantSeparator = "#"
+
+// TenantResolver returns the tenant the given call is made on behalf of
+type TenantResolver func(ctx context.Context) (string, error)
+
+// tenantPrefix returns the partition key prefix of the tenant of the call.
+// It is empty when multi-tenancy is disabled.
+func (d *DynamoDurableStore) tenantPrefix(ctx context.Context) (string, error) {
+	if d.tenant == nil {
+		return "", nil
+	}
+
+	tenantID, err := d.tenant(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the tenant: %w", err)
+	}
+	// a separator in the tenant ID would let a tenant address the keys of another one
+	if tenantID == "" || strings.Contains(tenantID, tenantSeparator) {
+		return "", fmt.Errorf("invalid tenant id=%q", tenantID)
+	}
+	return tenantID + tenantSeparator, nil
+}
+
+// partitionKey returns the partition key of the given persistence ID for the tenant of the call
+func (d *DynamoDurableStore) partitionKey(ctx context.Context, persistenceID string) (string, error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+	return prefix + persistenceID, nil
+}
+
+// persistenceIDOf strips the tenant prefix from a partition key. It fails when the key
+// does not belong to the tenant.
+func persistenceIDOf(prefix, partitionKey string) (string, error) {
+	persistenceID, ok := strings.CutPrefix(partitionKey, prefix)
+	if !ok {
+		return "", fmt.Errorf("item=%s does not belong to the tenant", partitionKey)
+	}
+	return persistenceID, nil
+}
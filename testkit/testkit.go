@@ -0,0 +1,109 @@
+// Package testkit runs DynamoDB Local in a container so that users of the DynamoDB durable
+// store can write integration tests for their ego entities without hand-rolled fixtures.
+//
+//	container, err := testkit.Start(ctx)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer container.Terminate(ctx)
+//
+//	store, err := container.NewStateStore(ctx)
+package testkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	dynamodb "github.com/sdil/ego-dynamodb-durablestore"
+)
+
+const (
+	// DefaultImage is the DynamoDB Local image started by Start
+	DefaultImage = "amazon/dynamodb-local:2.5.3"
+
+	dynamoPort = "8000/tcp"
+	region     = "us-east-1"
+)
+
+// Container is a running DynamoDB Local container
+type Container struct {
+	container testcontainers.Container
+	endpoint  string
+}
+
+// Start starts an in-memory DynamoDB Local container from DefaultImage
+func Start(ctx context.Context) (*Container, error) {
+	return StartImage(ctx, DefaultImage)
+}
+
+// StartImage starts an in-memory DynamoDB Local container from the given image
+func StartImage(ctx context.Context, image string) (*Container, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{dynamoPort},
+			Cmd:          []string{"-jar", "DynamoDBLocal.jar", "-inMemory", "-sharedDb"},
+			WaitingFor:   wait.ForListeningPort(dynamoPort),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the dynamodb local container: %w", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to get the container host: %w", err), container.Terminate(ctx))
+	}
+	port, err := container.MappedPort(ctx, dynamoPort)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to get the container port: %w", err), container.Terminate(ctx))
+	}
+
+	return &Container{
+		container: container,
+		endpoint:  fmt.Sprintf("http://%s:%s", host, port.Port()),
+	}, nil
+}
+
+// Endpoint returns the URL of DynamoDB Local
+func (c *Container) Endpoint() string {
+	return c.endpoint
+}
+
+// AWSConfig returns an AWS configuration with static credentials accepted by DynamoDB Local
+func (c *Container) AWSConfig() aws.Config {
+	return aws.Config{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider("local", "local", ""),
+	}
+}
+
+// NewStateStore returns a connected durable store targeting the container, with its table
+// created. The given options are applied after the ones pointing the store to the container.
+func (c *Container) NewStateStore(ctx context.Context, opts ...dynamodb.Option) (*dynamodb.DynamoDurableStore, error) {
+	opts = append([]dynamodb.Option{
+		dynamodb.WithAWSConfig(c.AWSConfig()),
+		dynamodb.WithEndpoint(c.endpoint),
+	}, opts...)
+
+	store := dynamodb.NewStateStore(opts...)
+	if err := store.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect the durable store: %w", err)
+	}
+	if err := store.EnsureTable(ctx); err != nil {
+		return nil, errors.Join(err, store.Disconnect(ctx))
+	}
+	return store, nil
+}
+
+// Terminate stops and removes the container
+func (c *Container) Terminate(ctx context.Context) error {
+	return c.container.Terminate(ctx)
+}
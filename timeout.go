@@ -0,0 +1,59 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds the duration of every single DynamoDB call made by the store, on top of
+// the deadline of the caller's context. A zero duration means no bound.
+type Timeouts struct {
+	// Default applies to the calls without a more specific timeout
+	Default time.Duration
+	// Read applies to the calls reading the table
+	Read time.Duration
+	// Write applies to the calls modifying the table
+	Write time.Duration
+}
+
+// writeOperations lists the operations modifying the table
+var writeOperations = map[string]bool{
+	OperationWriteState:     true,
+	OperationWriteOutbox:    true,
+	OperationMarkDelivered:  true,
+	OperationClaimOutbox:    true,
+	OperationFlush:          true,
+	OperationDeleteState:    true,
+	OperationRestoreState:   true,
+	OperationWriteStates:    true,
+	OperationImport:         true,
+	OperationTrimHistory:    true,
+	OperationReapTombstones: true,
+	OperationSaveCheckpoint: true,
+	OperationSaveSnapshot:   true,
+	OperationPruneSnapshots: true,
+}
+
+// forOperation returns the timeout of a single call of the given operation
+func (t Timeouts) forOperation(operation string) time.Duration {
+	timeout := t.Read
+	if writeOperations[operation] {
+		timeout = t.Write
+	}
+	if timeout <= 0 {
+		return t.Default
+	}
+	return timeout
+}
+
+// withOperationTimeout derives the context of a single call of the given operation
+func (d *DynamoDurableStore) withOperationTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := callOptionsFrom(ctx).timeout
+	if timeout <= 0 {
+		timeout = d.timeouts.forOperation(operation)
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
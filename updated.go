@@ -0,0 +1,167 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+const (
+	// updatedBuckets is the number of UpdatedIndex partitions the writes of a day are spread
+	// over, so that the index does not throttle the writes of the table
+	updatedBuckets = 16
+	// updatedDayLayout formats the day part of an UpdatedBucket
+	updatedDayLayout = "2006-01-02"
+	// updatedSincePageSize is the maximum number of states of a GetStatesUpdatedSince page
+	updatedSincePageSize = 100
+	// updatedSinceMaxQueries is the maximum number of Query calls made for a
+	// GetStatesUpdatedSince page, four days of buckets when they are empty
+	updatedSinceMaxQueries = 4 * updatedBuckets
+)
+
+// updatedBucket returns the UpdatedIndex partition of an item written at the given Unix
+// milliseconds: the UTC day of the write followed by a bucket derived from the partition key
+func updatedBucket(partitionKey string, updatedAt int64) string {
+	day := time.UnixMilli(updatedAt).UTC().Format(updatedDayLayout)
+	return day + "#" + strconv.Itoa(int(fnv32a(fnvOffset32, partitionKey)%updatedBuckets))
+}
+
+// updatedCursor is the position of a GetStatesUpdatedSince page, encoded in its page token
+type updatedCursor struct {
+	Day          string `json:"d"`
+	Bucket       int    `json:"b"`
+	UpdatedAt    int64  `json:"u,omitempty"`
+	PartitionKey string `json:"k,omitempty"`
+}
+
+// GetStatesUpdatedSince returns a page of the durable states written at or after the given
+// time, so that incremental jobs only read the recently changed states. An empty pageToken
+// fetches the first page; the returned nextPageToken is empty once every state written up to
+// now has been returned. A page can hold fewer states than the page size, or none, and still
+// have a nextPageToken: a call stops after 64 Query calls, four days of the index, so that a
+// since far in the past does not read every day up to now at once. States are not ordered and a state written again while paging may be
+// returned twice. Deleted states are skipped.
+// The table must have the UpdatedIndex global secondary index, see EnsureTable. The states
+// written before the index was introduced are not indexed until their next write.
+func (d *DynamoDurableStore) GetStatesUpdatedSince(ctx context.Context, since time.Time, pageToken string) (states []*egopb.DurableState, nextPageToken string, err error) {
+	prefix, err := d.tenantPrefix(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cursor := &updatedCursor{Day: since.UTC().Format(updatedDayLayout)}
+	if pageToken != "" {
+		if cursor, err = decodeUpdatedCursor(pageToken); err != nil {
+			return nil, "", err
+		}
+	}
+	today := d.clock.Now().UTC().Format(updatedDayLayout)
+
+	for queries := 0; len(states) < updatedSincePageSize && queries < updatedSinceMaxQueries; queries++ {
+		input := &dynamodb.QueryInput{
+			TableName:                aws.String(d.schema.TableName),
+			IndexName:                aws.String(UpdatedIndex),
+			KeyConditionExpression:   aws.String("#UpdatedBucket = :bucket AND #UpdatedAt >= :since"),
+			ExpressionAttributeNames: d.schema.names("UpdatedBucket", "UpdatedAt"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":bucket": &types.AttributeValueMemberS{Value: cursor.Day + "#" + strconv.Itoa(cursor.Bucket)},
+				":since":  &types.AttributeValueMemberN{Value: strconv.FormatInt(since.UnixMilli(), 10)},
+			},
+			Limit:                  aws.Int32(int32(updatedSincePageSize - len(states))),
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		}
+		if prefix != "" {
+			input.FilterExpression = aws.String("begins_with(#PersistenceID, :tenant)")
+			input.ExpressionAttributeNames = d.schema.names("UpdatedBucket", "UpdatedAt", "PersistenceID")
+			input.ExpressionAttributeValues[":tenant"] = &types.AttributeValueMemberS{Value: prefix}
+		}
+		if cursor.PartitionKey != "" {
+			input.ExclusiveStartKey = d.schema.key(cursor.PartitionKey)
+			input.ExclusiveStartKey[d.schema.attr("UpdatedBucket")] = input.ExpressionAttributeValues[":bucket"]
+			input.ExclusiveStartKey[d.schema.attr("UpdatedAt")] = &types.AttributeValueMemberN{Value: strconv.FormatInt(cursor.UpdatedAt, 10)}
+		}
+
+		var resp *dynamodb.QueryOutput
+		err = d.execute(ctx, OperationGetUpdatedSince, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().Query(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationGetUpdatedSince, resp.ConsumedCapacity)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch the states updated since=%s from the dynamodb: %w", since, err)
+		}
+
+		for _, attributes := range resp.Items {
+			item, err := d.schema.unmarshal(attributes)
+			if err != nil {
+				return nil, "", err
+			}
+			if item.DeletedAt > 0 {
+				continue
+			}
+			state, err := d.toDurableState(ctx, item, prefix, OperationGetUpdatedSince)
+			if err != nil {
+				return nil, "", err
+			}
+			states = append(states, state)
+		}
+
+		if resp.LastEvaluatedKey != nil {
+			cursor.PartitionKey = parseDynamoString(resp.LastEvaluatedKey[d.schema.PartitionKey])
+			if cursor.UpdatedAt, err = parseDynamoInt64(resp.LastEvaluatedKey[d.schema.attr("UpdatedAt")]); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if !cursor.next(today) {
+			return states, "", nil
+		}
+	}
+	return states, cursor.encode(), nil
+}
+
+// next moves the cursor to the start of the following bucket and reports whether it is not
+// past the given day
+func (c *updatedCursor) next(today string) bool {
+	c.PartitionKey, c.UpdatedAt = "", 0
+	c.Bucket++
+	if c.Bucket < updatedBuckets {
+		return true
+	}
+
+	day, err := time.Parse(updatedDayLayout, c.Day)
+	if err != nil {
+		return false
+	}
+	c.Day, c.Bucket = day.AddDate(0, 0, 1).Format(updatedDayLayout), 0
+	return c.Day <= today
+}
+
+// encode converts the cursor into an opaque page token
+func (c *updatedCursor) encode() string {
+	bytea, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(bytea)
+}
+
+// decodeUpdatedCursor converts a page token back into its cursor
+func decodeUpdatedCursor(pageToken string) (*updatedCursor, error) {
+	bytea, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	cursor := new(updatedCursor)
+	if err := json.Unmarshal(bytea, cursor); err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	return cursor, nil
+}
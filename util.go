@@ -1,39 +1,85 @@
 package dynamodb
 
 import (
-	"strconv"
+	"errors"
 	"fmt"
+	"strconv"
 
-	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
-	"google.golang.org/protobuf/types/known/anypb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// toProto converts a byte array given its manifest into a valid proto message
-func toProto(manifest string, bytea []byte) (*anypb.Any, error) {
-	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(manifest))
+// parseDynamoUint64 returns the value of a number attribute, zero when it is missing as in the
+// items written before the attribute existed. Another type or an invalid number is corrupt.
+func parseDynamoUint64(element types.AttributeValue) (uint64, error) {
+	number, err := dynamoNumber(element)
+	if err != nil || number == "" {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to parse the number attribute: %w", withClass(ErrCorruptPayload, err))
 	}
+	return n, nil
+}
 
-	pm := mt.New().Interface()
-	err = proto.Unmarshal(bytea, pm)
+// parseDynamoInt64 returns the value of a number attribute, zero when it is missing as in the
+// items written before the attribute existed. Another type or an invalid number is corrupt.
+func parseDynamoInt64(element types.AttributeValue) (int64, error) {
+	number, err := dynamoNumber(element)
+	if err != nil || number == "" {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to parse the number attribute: %w", withClass(ErrCorruptPayload, err))
+	}
+	return n, nil
+}
+
+// dynamoNumber returns the digits of a number attribute, empty when the attribute is missing
+func dynamoNumber(element types.AttributeValue) (string, error) {
+	switch value := element.(type) {
+	case nil:
+		return "", nil
+	case *types.AttributeValueMemberN:
+		return value.Value, nil
+	default:
+		return "", fmt.Errorf("failed to parse the number attribute: %w", withClass(ErrCorruptPayload, fmt.Errorf("unexpected attribute type %T", element)))
 	}
+}
 
-	if cast, ok := pm.(*anypb.Any); ok {
-		return cast, nil
+// isThrottlingError reports whether the given error is DynamoDB rejecting the request
+// because the table or account throughput has been exceeded
+func isThrottlingError(err error) bool {
+	var provisioned *types.ProvisionedThroughputExceededException
+	if errors.As(err, &provisioned) {
+		return true
 	}
-	return nil, fmt.Errorf("failed to unpack message=%s", manifest)
+	var requestLimit *types.RequestLimitExceeded
+	return errors.As(err, &requestLimit)
 }
 
-func parseDynamoUint64(element types.AttributeValue) uint64 {
-	n, _ := strconv.ParseUint(element.(*types.AttributeValueMemberN).Value, 10, 64)
-	return n
+// parseDynamoOptionalInt64 returns the value of an optional number attribute
+func parseDynamoOptionalInt64(element types.AttributeValue) int64 {
+	if number, ok := element.(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(number.Value, 10, 64)
+		return n
+	}
+	return 0
 }
 
-func parseDynamoInt64(element types.AttributeValue) int64 {
-	n, _ := strconv.ParseInt(element.(*types.AttributeValueMemberN).Value, 10, 64)
-	return n
+// parseDynamoString returns the value of an optional string attribute
+func parseDynamoString(element types.AttributeValue) string {
+	if s, ok := element.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+// parseDynamoBytes returns the value of an optional binary attribute
+func parseDynamoBytes(element types.AttributeValue) []byte {
+	if b, ok := element.(*types.AttributeValueMemberB); ok {
+		return b.Value
+	}
+	return nil
 }
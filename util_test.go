@@ -0,0 +1,35 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseDynamoNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   types.AttributeValue
+		want    int64
+		wantErr error
+	}{
+		{name: "number", value: &types.AttributeValueMemberN{Value: "42"}, want: 42},
+		{name: "missing", value: nil, want: 0},
+		{name: "string", value: &types.AttributeValueMemberS{Value: "42"}, wantErr: ErrCorruptPayload},
+		{name: "invalid number", value: &types.AttributeValueMemberN{Value: "4.2"}, wantErr: ErrCorruptPayload},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signed, err := parseDynamoInt64(test.value)
+			if !errors.Is(err, test.wantErr) || signed != test.want {
+				t.Fatalf("parseDynamoInt64 = %d, %v, want %d, %v", signed, err, test.want, test.wantErr)
+			}
+			unsigned, err := parseDynamoUint64(test.value)
+			if !errors.Is(err, test.wantErr) || unsigned != uint64(test.want) {
+				t.Fatalf("parseDynamoUint64 = %d, %v, want %d, %v", unsigned, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tochemey/ego/v3/egopb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// MaxItemSize is the DynamoDB item size limit
+const MaxItemSize = 400 * 1024
+
+// ValidationPolicy defines the checks made on every state before it is written
+type ValidationPolicy struct {
+	// MaxItemSize is the maximum size in bytes of a state item, compression and S3 offloading
+	// applied. Zero means the DynamoDB item size limit, MaxItemSize.
+	MaxItemSize int
+	// ResolveManifest verifies the manifest of the state resolves to a message type
+	ResolveManifest bool
+	// CheckTypeURL verifies the type URL of the state resolves to a message type and, when the
+	// state is not serialized as a google.protobuf.Any, that it matches the manifest
+	CheckTypeURL bool
+	// Resolver resolves the message types, protoregistry.GlobalTypes when nil
+	Resolver protoregistry.MessageTypeResolver
+}
+
+// validateState verifies the serialized state against the validation policy
+func (d *DynamoDurableStore) validateState(state *egopb.DurableState, manifest string) error {
+	if d.validation == nil {
+		return nil
+	}
+
+	resolver := d.validation.Resolver
+	if resolver == nil {
+		resolver = protoregistry.GlobalTypes
+	}
+
+	if d.validation.ResolveManifest {
+		if _, err := resolver.FindMessageByName(protoreflect.FullName(manifest)); err != nil {
+			return fmt.Errorf("manifest=%s of persistenceID=%s does not resolve: %w", manifest, state.GetPersistenceId(), withClass(ErrInvalidState, err))
+		}
+	}
+
+	if d.validation.CheckTypeURL {
+		typeURL := state.GetResultingState().GetTypeUrl()
+		if _, err := resolver.FindMessageByURL(typeURL); err != nil {
+			return fmt.Errorf("type url=%s of persistenceID=%s does not resolve: %w", typeURL, state.GetPersistenceId(), withClass(ErrInvalidState, err))
+		}
+		if name := typeURL[strings.LastIndex(typeURL, "/")+1:]; manifest != anyManifest && name != manifest {
+			return fmt.Errorf("type url=%s of persistenceID=%s does not match manifest=%s: %w", typeURL, state.GetPersistenceId(), manifest, ErrInvalidState)
+		}
+	}
+	return nil
+}
+
+// validateItemSize verifies the encoded item fits within the configured maximum item size
+func (d *DynamoDurableStore) validateItemSize(item *StateItem) error {
+	if d.validation == nil {
+		return nil
+	}
+
+	maxSize := d.validation.MaxItemSize
+	if maxSize <= 0 {
+		maxSize = MaxItemSize
+	}
+	if size := itemSize(d.schema.marshal(item)); size > maxSize {
+		return fmt.Errorf("item of persistenceID=%s is %d bytes, above the maximum of %d bytes: %w", item.PersistenceID, size, maxSize, ErrItemTooLarge)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// WarmUp configures the work done by Connect so that the first calls after a deployment,
+// typically the recoveries of the actors, do not pay the cold start of the AWS clients
+type WarmUp struct {
+	// MaxIdleConns is the number of idle HTTP connections kept open to DynamoDB.
+	// Zero keeps the default of the SDK. It is ignored when the AWS configuration
+	// given with WithAWSConfig carries its own HTTP client.
+	MaxIdleConns int
+	// Connections is the number of HTTP connections opened by Connect, one DescribeTable
+	// call being issued on each of them. It defaults to one.
+	Connections int
+}
+
+// configure sizes the HTTP connection pool of the given AWS configuration
+func (w *WarmUp) configure(cfg aws.Config) aws.Config {
+	if w == nil || w.MaxIdleConns <= 0 {
+		return cfg
+	}
+
+	client, ok := cfg.HTTPClient.(*awshttp.BuildableClient)
+	if !ok {
+		if cfg.HTTPClient != nil {
+			return cfg
+		}
+		client = awshttp.NewBuildableClient()
+	}
+	cfg.HTTPClient = client.WithTransportOptions(func(transport *http.Transport) {
+		transport.MaxIdleConns = w.MaxIdleConns
+		transport.MaxIdleConnsPerHost = w.MaxIdleConns
+	})
+	return cfg
+}
+
+// warmUp resolves the credentials and opens the HTTP connections to DynamoDB. A failure to
+// resolve the credentials fails Connect; a failure to describe the table, missing until
+// EnsureTable creates it for instance, is only logged as the connection is opened anyway.
+func (d *DynamoDurableStore) warmUp(ctx context.Context, cfg aws.Config) error {
+	if d.warmUpConfig == nil {
+		return nil
+	}
+
+	if cfg.Credentials != nil {
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			return fmt.Errorf("failed to resolve the AWS credentials: %w", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range max(d.warmUpConfig.Connections, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := d.client.Load().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+				TableName: aws.String(d.schema.TableName),
+			})
+			if err != nil {
+				d.logger.Warn("failed to describe the table while warming up", "table", d.schema.TableName, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
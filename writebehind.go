@@ -0,0 +1,311 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+// maxBatchWriteItems is the maximum number of items of a BatchWriteItem call
+const maxBatchWriteItems = 25
+
+// writeBehind buffers the written states and persists them in batches.
+// Several writes of the same persistence ID between two flushes are coalesced into the
+// latest version.
+type writeBehind struct {
+	mu       sync.Mutex
+	pending  map[string]*StateItem
+	inflight map[string]*StateItem
+	// flushing serializes the flushes
+	flushing sync.Mutex
+
+	interval time.Duration
+	maxItems int
+	// stop and done are recreated every time the store connects
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newWriteBehind creates a write-behind buffer flushed every interval or once it holds maxItems states
+func newWriteBehind(interval time.Duration, maxItems int) *writeBehind {
+	if maxItems <= 0 {
+		maxItems = maxBatchWriteItems
+	}
+	return &writeBehind{
+		pending:  make(map[string]*StateItem),
+		inflight: make(map[string]*StateItem),
+		interval: interval,
+		maxItems: maxItems,
+	}
+}
+
+// start prepares the channels of a new run of the background flushes
+func (w *writeBehind) start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+}
+
+// add buffers the item unless a newer version is already buffered and reports whether
+// the buffer is full
+func (w *writeBehind) add(item *StateItem) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if existing, ok := w.pending[item.PersistenceID]; !ok || existing.VersionNumber <= item.VersionNumber {
+		w.pending[item.PersistenceID] = item
+	}
+	return len(w.pending) >= w.maxItems
+}
+
+// get returns the buffered item of the given partition key, if any
+func (w *writeBehind) get(partitionKey string) (*StateItem, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if item, ok := w.pending[partitionKey]; ok {
+		return item, true
+	}
+	item, ok := w.inflight[partitionKey]
+	return item, ok
+}
+
+// take moves the pending items to the in-flight ones and returns them
+func (w *writeBehind) take() []*StateItem {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	items := make([]*StateItem, 0, len(w.pending))
+	for key, item := range w.pending {
+		w.inflight[key] = item
+		items = append(items, item)
+	}
+	w.pending = make(map[string]*StateItem)
+	return items
+}
+
+// release forgets the in-flight items and puts back the failed ones into the buffer,
+// unless a newer version has been written in the meantime
+func (w *writeBehind) release(failed []*StateItem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, item := range failed {
+		if existing, ok := w.pending[item.PersistenceID]; !ok || existing.VersionNumber < item.VersionNumber {
+			w.pending[item.PersistenceID] = item
+		}
+	}
+	w.inflight = make(map[string]*StateItem)
+}
+
+// runWriteBehind flushes the buffer every interval until the buffer is closed
+func (d *DynamoDurableStore) runWriteBehind() {
+	stop, done := d.writeBehind.stop, d.writeBehind.done
+	defer close(done)
+
+	ticker := time.NewTicker(d.writeBehind.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = d.flush(context.Background())
+		}
+	}
+}
+
+// Flush synchronously persists the states buffered by the write-behind mode, including the
+// ones a previous flush failed to persist. Flush is a no-op when the write-behind mode is disabled.
+func (d *DynamoDurableStore) Flush(ctx context.Context) error {
+	if d.writeBehind == nil {
+		return nil
+	}
+	return d.flush(ctx)
+}
+
+// flush persists the buffered states with BatchWriteItem. The states that could not be
+// persisted are put back into the buffer.
+func (d *DynamoDurableStore) flush(ctx context.Context) error {
+	d.writeBehind.flushing.Lock()
+	defer d.writeBehind.flushing.Unlock()
+
+	items := d.writeBehind.take()
+	var (
+		failed []*StateItem
+		errs   []error
+	)
+	for start := 0; start < len(items); start += maxBatchWriteItems {
+		end := min(start+maxBatchWriteItems, len(items))
+		if err := d.batchWrite(ctx, OperationFlush, items[start:end]); err != nil {
+			failed = append(failed, items[start:end]...)
+			errs = append(errs, err)
+		}
+	}
+	d.writeBehind.release(failed)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to flush the buffered states into the dynamodb: %w", err)
+	}
+	return nil
+}
+
+// batchWrite persists up to 25 items with distinct partition keys, retrying the unprocessed ones
+func (d *DynamoDurableStore) batchWrite(ctx context.Context, operation string, items []*StateItem) error {
+	requests := make([]types.WriteRequest, 0, len(items))
+	for _, item := range items {
+		requests = append(requests, types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: d.schema.marshal(item)},
+		})
+	}
+	return d.batchWriteRequests(ctx, operation, d.schema.TableName, requests)
+}
+
+// batchWriteRequests runs up to 25 write requests against the given table, retrying the unprocessed ones
+func (d *DynamoDurableStore) batchWriteRequests(ctx context.Context, operation, table string, requests []types.WriteRequest) error {
+	for attempt := 1; len(requests) > 0; attempt++ {
+		var resp *dynamodb.BatchWriteItemOutput
+		err := d.execute(ctx, operation, func(ctx context.Context) (err error) {
+			resp, err = d.client.Load().BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems:           map[string][]types.WriteRequest{table: requests},
+				ReturnConsumedCapacity: d.returnConsumedCapacity(),
+			})
+			if err == nil {
+				for index := range resp.ConsumedCapacity {
+					d.observeConsumedCapacity(ctx, operation, &resp.ConsumedCapacity[index])
+				}
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		requests = resp.UnprocessedItems[table]
+		if len(requests) == 0 {
+			return nil
+		}
+		d.logger.Warn("dynamodb batch write left unprocessed items", "operation", operation, "count", len(requests), "attempt", attempt)
+		if attempt >= max(d.retry.MaxAttempts, 1) {
+			return fmt.Errorf("%d items left unprocessed: %w", len(requests), ErrThrottled)
+		}
+		if err := sleep(ctx, d.retry.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStates persists several durable states with BatchWriteItem. When a persistence ID
+// appears several times only its latest version is written. Unlike WriteState, the states
+// are written without transaction: on failure some of them may have been persisted.
+func (d *DynamoDurableStore) WriteStates(ctx context.Context, states []*egopb.DurableState) error {
+	positions := make(map[string]int, len(states))
+	items := make([]*StateItem, 0, len(states))
+	latest := make([]*egopb.DurableState, 0, len(states))
+	for _, state := range states {
+		item, err := d.newStateItem(ctx, state, OperationWriteStates)
+		if err != nil {
+			return err
+		}
+		if position, ok := positions[item.PersistenceID]; ok {
+			if items[position].VersionNumber < item.VersionNumber {
+				items[position], latest[position] = item, state
+			}
+			continue
+		}
+		positions[item.PersistenceID] = len(items)
+		items = append(items, item)
+		latest = append(latest, state)
+	}
+
+	for start := 0; start < len(items); start += maxBatchWriteItems {
+		end := min(start+maxBatchWriteItems, len(items))
+		if err := d.batchWrite(ctx, OperationWriteStates, items[start:end]); err != nil {
+			for _, item := range items[start:end] {
+				d.invalidateCache(item.PersistenceID)
+			}
+			return fmt.Errorf("failed to write the states into the dynamodb: %w", err)
+		}
+		for position := start; position < end; position++ {
+			d.updateCache(items[position].PersistenceID, latest[position])
+		}
+	}
+	return nil
+}
+
+// WriteStatesIfNewer persists the durable states newer than the stored ones and returns how
+// many were written. Unlike WriteStates, every state is written with a conditional PutItem, so
+// that a state written concurrently, for instance by a live entity, is never replaced by an
+// older version. The states are written one by one: on failure the previous ones are persisted.
+func (d *DynamoDurableStore) WriteStatesIfNewer(ctx context.Context, states []*egopb.DurableState) (int, error) {
+	// a buffered write must be stored for the condition to take it into account
+	if err := d.Flush(ctx); err != nil {
+		return 0, err
+	}
+
+	var written int
+	for _, state := range states {
+		item, err := d.newStateItem(ctx, state, OperationWriteStates)
+		if err != nil {
+			return written, err
+		}
+
+		input := &dynamodb.PutItemInput{
+			TableName:                aws.String(d.schema.TableName),
+			Item:                     d.stateAttributes(item),
+			ConditionExpression:      aws.String("attribute_not_exists(#PersistenceID) OR #VersionNumber < :version"),
+			ExpressionAttributeNames: d.schema.names("PersistenceID", "VersionNumber"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.FormatUint(item.VersionNumber, 10)},
+			},
+			ReturnConsumedCapacity: d.returnConsumedCapacity(),
+		}
+		err = d.execute(ctx, OperationWriteStates, func(ctx context.Context) error {
+			resp, err := d.client.Load().PutItem(ctx, input)
+			if err == nil {
+				d.observeConsumedCapacity(ctx, OperationWriteStates, resp.ConsumedCapacity)
+			}
+			return err
+		})
+
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			d.invalidateCache(item.PersistenceID)
+			return written, fmt.Errorf("failed to write the state of persistenceID=%s into the dynamodb: %w", state.GetPersistenceId(), err)
+		}
+		d.updateCache(item.PersistenceID, state)
+		written++
+	}
+	return written, nil
+}
+
+// closeWriteBehind stops the background flushes and flushes the buffer a last time.
+// The lifecycle lock must be held.
+func (d *DynamoDurableStore) closeWriteBehind(ctx context.Context) error {
+	close(d.writeBehind.stop)
+	<-d.writeBehind.done
+	return d.Flush(ctx)
+}
+
+// writeStateBehind buffers the item instead of writing it, flushing the buffer when full
+func (d *DynamoDurableStore) writeStateBehind(ctx context.Context, item *StateItem) error {
+	if d.writeBehind.add(item) {
+		return d.flush(ctx)
+	}
+	return nil
+}
+
+// bufferedItem returns the item of the given partition key waiting to be flushed, if any
+func (d *DynamoDurableStore) bufferedItem(partitionKey string) (*StateItem, bool) {
+	if d.writeBehind == nil {
+		return nil, false
+	}
+	return d.writeBehind.get(partitionKey)
+}
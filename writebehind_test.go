@@ -0,0 +1,51 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tochemey/ego/v3/egopb"
+)
+
+func TestWriteStatesIfNewer(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newFakeStore(t)
+	for _, state := range []*egopb.DurableState{testState(t, "order-1", 5), testState(t, "order-2", 5)} {
+		if err := store.WriteState(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	written, err := store.WriteStatesIfNewer(ctx, []*egopb.DurableState{
+		testState(t, "order-1", 3),
+		testState(t, "order-2", 5),
+		testState(t, "order-3", 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 {
+		t.Fatalf("WriteStatesIfNewer = %d, want 1", written)
+	}
+
+	for persistenceID, want := range map[string]uint64{"order-1": 5, "order-2": 5, "order-3": 1} {
+		state, err := store.GetLatestState(ctx, persistenceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertDurableState(t, state, testState(t, persistenceID, want))
+	}
+
+	written, err = store.WriteStatesIfNewer(ctx, []*egopb.DurableState{testState(t, "order-1", 6)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 {
+		t.Fatalf("WriteStatesIfNewer of a newer state = %d, want 1", written)
+	}
+	state, err := store.GetLatestState(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDurableState(t, state, testState(t, "order-1", 6))
+}